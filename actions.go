@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	actionsAll       = "all"
+	actionsLocalOnly = "local_only"
+	actionsSelected  = "selected"
+
+	// forkApprovalNewToGitHub is the least strict fork pull request approval
+	// policy: only workflows of contributors new to GitHub need approval.
+	forkApprovalNewToGitHub = "first_time_contributors_new_to_github"
+)
+
+// The following types are the Actions settings of a repository.
+
+type actionsPermissions struct {
+	Enabled        bool   `json:"enabled"`
+	AllowedActions string `json:"allowed_actions"`
+}
+
+type workflowPermissions struct {
+	DefaultWorkflowPermissions   string `json:"default_workflow_permissions"`
+	CanApprovePullRequestReviews bool   `json:"can_approve_pull_request_reviews"`
+}
+
+type forkPRApproval struct {
+	ApprovalPolicy string `json:"approval_policy"`
+}
+
+type actionsSecrets struct {
+	TotalCount int `json:"total_count"`
+	Secrets    []struct {
+		Name string `json:"name"`
+	} `json:"secrets"`
+}
+
+// auditActions adds the Actions settings of the repository and the names of
+// its secrets to the report, recording in it what could not be fetched. Most
+// of them are only visible to the admins of the repository.
+func (a *auditor) auditActions(ctx context.Context, repo ghrepo, report *repoReport) error {
+	base := fmt.Sprintf("repos/%s/%s", repo.Owner.Login, repo.Name)
+
+	logrus.Debugf("Executing REST query to get Actions permissions for %s", repo.NameWithOwner)
+	var perms actionsPermissions
+	if _, err := a.restGet(ctx, base+"/actions/permissions", &perms); err != nil {
+		if isRateLimit(err) {
+			return err
+		}
+		report.addError("Actions permissions", err)
+		return nil
+	}
+
+	actions := &actionsReport{
+		Enabled:        perms.Enabled,
+		AllowedActions: perms.AllowedActions,
+		Secrets:        []string{},
+	}
+	report.Actions = actions
+	if !perms.Enabled {
+		return nil
+	}
+
+	logrus.Debugf("Executing REST query to get workflow permissions for %s", repo.NameWithOwner)
+	var workflow workflowPermissions
+	if _, err := a.restGet(ctx, base+"/actions/permissions/workflow", &workflow); err != nil {
+		if isRateLimit(err) {
+			return err
+		}
+		report.addError("workflow permissions", err)
+	} else {
+		actions.DefaultWorkflowPermissions = workflow.DefaultWorkflowPermissions
+		actions.CanApprovePullRequestReviews = workflow.CanApprovePullRequestReviews
+	}
+
+	// Only public repositories can run the workflows of pull requests from
+	// forks by anyone.
+	if !repo.IsPrivate {
+		logrus.Debugf("Executing REST query to get fork pull request approval policy for %s", repo.NameWithOwner)
+		var approval forkPRApproval
+		if _, err := a.restGet(ctx, base+"/actions/permissions/fork-pr-contributor-approval", &approval); err != nil {
+			if isRateLimit(err) {
+				return err
+			}
+			report.addError("fork pull request approval policy", err)
+		} else {
+			actions.ForkPRApprovalPolicy = approval.ApprovalPolicy
+		}
+	}
+
+	logrus.Debugf("Executing REST query to list Actions secrets for %s", repo.NameWithOwner)
+	secrets, err := a.listSecretNames(ctx, base+"/actions/secrets")
+	if err != nil {
+		if isRateLimit(err) {
+			return err
+		}
+		report.addError("Actions secrets", err)
+	} else {
+		actions.Secrets = secrets
+	}
+
+	return nil
+}
+
+// listSecretNames lists the names of the secrets at path. The values of
+// secrets are never returned by the API.
+func (a *auditor) listSecretNames(ctx context.Context, path string) ([]string, error) {
+	names := []string{}
+	for page := 1; page != 0; {
+		var data actionsSecrets
+		resp, err := a.restGet(ctx, fmt.Sprintf("%s?per_page=100&page=%d", path, page), &data)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range data.Secrets {
+			names = append(names, s.Name)
+		}
+		page = resp.NextPage
+	}
+	return names, nil
+}
+
+// actionsFindings runs the Actions rules against a repository report.
+func actionsFindings(r *repoReport) []finding {
+	findings := []finding{}
+	if r.Actions == nil || !r.Actions.Enabled {
+		return findings
+	}
+
+	if r.Actions.DefaultWorkflowPermissions == "write" {
+		findings = append(findings, newFinding(ruleActionsTokenWrite, r.Repository, "default_workflow_permissions",
+			"workflows get a GITHUB_TOKEN with write access to the repository by default"))
+	}
+	if r.Actions.CanApprovePullRequestReviews {
+		findings = append(findings, newFinding(ruleActionsApprovePRs, r.Repository, "can_approve_pull_request_reviews",
+			"workflows can approve pull requests, so their approval can satisfy required reviews"))
+	}
+	if r.Actions.ForkPRApprovalPolicy == forkApprovalNewToGitHub {
+		findings = append(findings, newFinding(ruleActionsForkApproval, r.Repository, "approval_policy",
+			"workflows of pull requests from forks only need approval for contributors new to GitHub"))
+	}
+
+	return findings
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// newTestAuditor returns an auditor whose REST client answers every path in
+// responses with its status code and body, and everything else with a 404.
+func newTestAuditor(responses map[string]testResponse) (*auditor, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if resp.status != 0 {
+			w.WriteHeader(resp.status)
+		}
+		w.Write([]byte(resp.body))
+	}))
+
+	restClient := github.NewClient(srv.Client())
+	restClient.BaseURL, _ = url.Parse(srv.URL + "/")
+	return &auditor{restClient: restClient, productionEnvs: defaultProductionEnvs}, srv.Close
+}
+
+type testResponse struct {
+	status int
+	body   string
+}
+
+func TestAuditActionsNotVisible(t *testing.T) {
+	forbidden := testResponse{http.StatusForbidden, `{"message": "Must have admin rights to Repository."}`}
+	a, done := newTestAuditor(map[string]testResponse{
+		"/repos/genuinetools/audit/actions/permissions":                              {body: `{"enabled": true, "allowed_actions": "all"}`},
+		"/repos/genuinetools/audit/actions/permissions/workflow":                     forbidden,
+		"/repos/genuinetools/audit/actions/secrets":                                  forbidden,
+		"/repos/genuinetools/audit/actions/permissions/fork-pr-contributor-approval": {http.StatusInternalServerError, `{"message": "Server Error"}`},
+	})
+	defer done()
+
+	repo := ghrepo{Name: "audit", NameWithOwner: "genuinetools/audit"}
+	repo.Owner.Login = "genuinetools"
+	report := newRepoReport(repo)
+	if err := a.auditActions(context.Background(), repo, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Actions == nil || !report.Actions.Enabled || report.Actions.AllowedActions != actionsAll {
+		t.Errorf("actions = %+v, want them enabled for all actions", report.Actions)
+	}
+	if want := []string{"workflow permissions", "Actions secrets"}; !reflect.DeepEqual(report.NotVisible, want) {
+		t.Errorf("not visible = %v, want %v", report.NotVisible, want)
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("errors = %v, want only the one of the fork pull request approval policy", report.Errors)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	selectionAll      = "all"
+	selectionSelected = "selected"
+)
+
+// orgInstallations is a page of the GitHub App installations of an
+// organization.
+type orgInstallations struct {
+	TotalCount    int               `json:"total_count"`
+	Installations []orgInstallation `json:"installations"`
+}
+
+// orgInstallation is a GitHub App installation on an organization.
+type orgInstallation struct {
+	ID                  int64             `json:"id"`
+	AppSlug             string            `json:"app_slug"`
+	HTMLURL             string            `json:"html_url"`
+	RepositorySelection string            `json:"repository_selection"`
+	Permissions         map[string]string `json:"permissions"`
+	Events              []string          `json:"events"`
+	CreatedAt           time.Time         `json:"created_at"`
+	SuspendedAt         *time.Time        `json:"suspended_at"`
+}
+
+// listInstallations lists the GitHub App installations of the organization.
+func (a *auditor) listInstallations(ctx context.Context, org string) ([]orgInstallation, error) {
+	installations := []orgInstallation{}
+	for page := 1; page != 0; {
+		var data orgInstallations
+		resp, err := a.restGet(ctx, fmt.Sprintf("orgs/%s/installations?per_page=100&page=%d", org, page), &data)
+		if err != nil {
+			return nil, err
+		}
+		installations = append(installations, data.Installations...)
+		page = resp.NextPage
+	}
+	return installations, nil
+}
+
+// auditInstallations adds the GitHub App installations of the organization to
+// the report, or records in it why they could not be listed.
+func (a *auditor) auditInstallations(ctx context.Context, org string, report *orgReport) error {
+	logrus.Debugf("Executing REST query to list app installations of %s", org)
+	installations, err := a.listInstallations(ctx, org)
+	if err != nil {
+		if isRateLimit(err) {
+			return err
+		}
+		report.addError("app installations", err)
+		return nil
+	}
+
+	for _, i := range installations {
+		install := installationReport{
+			ID:                  i.ID,
+			App:                 i.AppSlug,
+			URL:                 i.HTMLURL,
+			RepositorySelection: i.RepositorySelection,
+			Permissions:         i.Permissions,
+			Events:              i.Events,
+			CreatedAt:           i.CreatedAt,
+			Suspended:           i.SuspendedAt != nil,
+		}
+		if install.Permissions == nil {
+			install.Permissions = map[string]string{}
+		}
+		if install.Events == nil {
+			install.Events = []string{}
+		}
+
+		report.Installations = append(report.Installations, install)
+	}
+	return nil
+}
+
+// writePermissions returns the repository permissions of the installation
+// that grant write or admin access, sorted.
+func (i installationReport) writePermissions() []string {
+	perms := []string{}
+	for name, level := range i.Permissions {
+		if !repoPermission(name) {
+			continue
+		}
+		if level == "write" || level == "admin" {
+			perms = append(perms, name+":"+level)
+		}
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// repoPermission reports whether the GitHub App permission applies to
+// repositories, rather than to the organization.
+func repoPermission(name string) bool {
+	return !strings.HasPrefix(name, "organization_") && name != "members" && name != "team_discussions"
+}
+
+// oauthAuditLogResponse holds the audit log entries about the OAuth App
+// access restrictions of an organization.
+type oauthAuditLogResponse struct {
+	Org struct {
+		Enabled  oauthAuditEntries `json:"enabled"`
+		Disabled oauthAuditEntries `json:"disabled"`
+		Approved oauthAuditEntries `json:"approved"`
+		Denied   oauthAuditEntries `json:"denied"`
+	} `json:"organization"`
+}
+
+type oauthAuditEntries struct {
+	Nodes []oauthAuditEntry `json:"nodes"`
+}
+
+type oauthAuditEntry struct {
+	CreatedAt            time.Time `json:"createdAt"`
+	OauthApplicationName string    `json:"oauthApplicationName"`
+}
+
+// auditOAuthApps adds whether OAuth App access restrictions are enabled on
+// the organization, and the OAuth Apps approved, to the report. GitHub only
+// exposes them through the audit log, which is only available to the owners
+// of GitHub Enterprise Cloud organizations.
+func (a *auditor) auditOAuthApps(ctx context.Context, org string, report *orgReport) {
+	logrus.Debugf("Executing GraphQL query to fetch OAuth App access restrictions of org %s", org)
+	var data oauthAuditLogResponse
+	if err := a.graphqlClient.Execute(GQLRequest{
+		Query:     queryGetOrgOAuthAuditLog,
+		Variables: map[string]interface{}{"login": org},
+	}, &data); err != nil {
+		// Most organizations have no audit log API, so this is not counted
+		// as an error and the restrictions are reported as unknown.
+		logrus.WithError(err).Debugf("reading OAuth App access restrictions of %s from the audit log failed", org)
+		return
+	}
+
+	// The most recent change decides, when there is none the restrictions
+	// were never changed and their default depends on when the organization
+	// was created.
+	enabled, disabled := latestEntry(data.Org.Enabled), latestEntry(data.Org.Disabled)
+	if !enabled.IsZero() || !disabled.IsZero() {
+		restricted := enabled.After(disabled)
+		report.OAuthAppRestrictions = &restricted
+	}
+
+	approved := map[string]time.Time{}
+	for _, e := range data.Org.Approved.Nodes {
+		if e.CreatedAt.After(approved[e.OauthApplicationName]) {
+			approved[e.OauthApplicationName] = e.CreatedAt
+		}
+	}
+	for _, e := range data.Org.Denied.Nodes {
+		if at, ok := approved[e.OauthApplicationName]; ok && e.CreatedAt.After(at) {
+			delete(approved, e.OauthApplicationName)
+		}
+	}
+	for name := range approved {
+		report.ApprovedOAuthApps = append(report.ApprovedOAuthApps, name)
+	}
+	sort.Strings(report.ApprovedOAuthApps)
+}
+
+// latestEntry returns when the most recent of the entries was created.
+func latestEntry(entries oauthAuditEntries) time.Time {
+	var latest time.Time
+	for _, e := range entries.Nodes {
+		if e.CreatedAt.After(latest) {
+			latest = e.CreatedAt
+		}
+	}
+	return latest
+}
+
+// appFindings runs the app rules against an organization report.
+func appFindings(o *orgReport) []finding {
+	findings := []finding{}
+
+	for _, i := range o.Installations {
+		if i.RepositorySelection != selectionAll {
+			continue
+		}
+		if perms := i.writePermissions(); len(perms) > 0 {
+			findings = append(findings, newFinding(ruleAppWriteAllRepos, o.Organization, i.App,
+				fmt.Sprintf("app %s can write to every repository (%s)", i.App, strings.Join(perms, ", "))))
+		}
+	}
+
+	if o.OAuthAppRestrictions != nil && !*o.OAuthAppRestrictions {
+		findings = append(findings, newFinding(ruleOAuthRestrictionsDisabled, o.Organization, "oauth_app_restrictions",
+			"OAuth App access restrictions are disabled, any OAuth App a member authorizes can access the organization"))
+	}
+
+	return findings
+}
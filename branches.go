@@ -0,0 +1,191 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// matchBranchPattern reports whether the branch name matches a GitHub branch
+// protection rule pattern.
+//
+// GitHub evaluates patterns with Ruby's File.fnmatch and the FNM_PATHNAME
+// flag, so wildcards never match a '/' with the exception of "**/" at the
+// start of a path segment which matches zero or more directories.
+// See https://help.github.com/articles/configuring-protected-branches/
+func matchBranchPattern(pattern, branch string) bool {
+	re, err := regexp.Compile(fnmatchToRegexp(pattern))
+	if err != nil {
+		return pattern == branch
+	}
+	return re.MatchString(branch)
+}
+
+// fnmatchToRegexp translates an fnmatch pattern into an anchored regular
+// expression.
+func fnmatchToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			segmentStart := i == 0 || pattern[i-1] == '/'
+			if segmentStart && strings.HasPrefix(pattern[i:], "**/") {
+				b.WriteString("(?:[^/]*/)*")
+				i += 2
+				continue
+			}
+			// Collapse consecutive stars, they are the same as one.
+			for i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := bracketEnd(pattern[i+1:])
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			b.WriteString(bracketToRegexp(class))
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
+
+// bracketEnd returns the index of the ']' closing a bracket expression, or -1
+// if there is none. A ']' right after the opening '[' or its negation is a
+// literal.
+func bracketEnd(s string) int {
+	start := 0
+	if strings.HasPrefix(s, "!") || strings.HasPrefix(s, "^") {
+		start++
+	}
+	if start < len(s) && s[start] == ']' {
+		start++
+	}
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// bracketToRegexp translates the contents of an fnmatch bracket expression
+// into a regular expression character class that never matches a '/'.
+func bracketToRegexp(class string) string {
+	negate := false
+	if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+		negate = true
+		class = class[1:]
+	}
+
+	// Read the class as single characters and lo-hi ranges, a '-' first or
+	// last is a literal.
+	type span struct{ lo, hi byte }
+	spans := []span{}
+	for i := 0; i < len(class); i++ {
+		lo := class[i]
+		if lo == '\\' && i+1 < len(class) {
+			i++
+			lo = class[i]
+		}
+		hi := lo
+		if i+2 < len(class) && class[i+1] == '-' {
+			i += 2
+			hi = class[i]
+			if hi == '\\' && i+1 < len(class) {
+				i++
+				hi = class[i]
+			}
+		}
+		spans = append(spans, span{lo, hi})
+	}
+
+	var b strings.Builder
+	write := func(c byte) {
+		if strings.IndexByte(`\[]^-`, c) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	for i := 0; i < len(spans); i++ {
+		sp := spans[i]
+		// Leave '/' out of the ranges, a negated class excludes it anyway.
+		if !negate && sp.lo <= '/' && '/' <= sp.hi {
+			if sp.lo < '/' {
+				spans = append(spans, span{sp.lo, '/' - 1})
+			}
+			if sp.hi > '/' {
+				spans = append(spans, span{'/' + 1, sp.hi})
+			}
+			continue
+		}
+		if sp.lo > sp.hi {
+			continue
+		}
+		write(sp.lo)
+		if sp.hi != sp.lo {
+			b.WriteByte('-')
+			write(sp.hi)
+		}
+	}
+
+	if negate {
+		return "[^/" + b.String() + "]"
+	}
+	if b.Len() == 0 {
+		// Nothing but '/' was listed, so nothing can match.
+		return `[^\x00-\x{10FFFF}]`
+	}
+	return "[" + b.String() + "]"
+}
+
+// protectingRules returns the patterns of the protection rules that cover
+// the branch.
+func protectingRules(rules []protectionRuleReport, branch string) []string {
+	patterns := []string{}
+	for _, r := range rules {
+		if matchBranchPattern(r.Pattern, branch) {
+			patterns = append(patterns, r.Pattern)
+		}
+	}
+	return patterns
+}
+
+// effectiveRule returns the protection rule GitHub applies to the branch.
+//
+// Only one rule applies to a branch: rules naming the branch exactly take
+// priority over rules with wildcards, and between rules of the same kind the
+// oldest one, which comes first, wins. The rules have to be in the order they
+// were created, handleRepo sorts them that way.
+func effectiveRule(rules []protectionRuleReport, branch string) (protectionRuleReport, bool) {
+	for _, r := range rules {
+		if r.Pattern == branch {
+			return r, true
+		}
+	}
+	for _, r := range rules {
+		if matchBranchPattern(r.Pattern, branch) {
+			return r, true
+		}
+	}
+	return protectionRuleReport{}, false
+}
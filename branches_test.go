@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestMatchBranchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		branch  string
+		want    bool
+	}{
+		{"master", "master", true},
+		{"master", "master2", false},
+		{"release/*", "release/1.0", true},
+		{"release/*", "release/1.0/hotfix", false},
+		{"*", "feature/x", false},
+		{"**/*", "feature/x", true},
+		{"**/hotfix", "hotfix", true},
+		{"**/hotfix", "release/1.0/hotfix", true},
+		{"v?", "v1", true},
+		{"v?", "v10", false},
+		{"feat?x", "feat/x", false},
+		{"v[0-9]", "v7", true},
+		{"v[0-9]", "va", false},
+		{"v[!0-9]", "va", true},
+		{"v[!0-9]", "v7", false},
+		{"feat[/]x", "feat/x", false},
+		{"feat[!a]x", "feat/x", false},
+		{"feat[!-0]x", "feat/x", false},
+		{"feat[!-0]x", "feat.x", true},
+		{"feat[!-0]x", "feat0x", false},
+		{"feat[.-0]x", "feat.x", true},
+		{"feat[.-0]x", "feat/x", false},
+		{"feat[.-0]x", "feat0x", true},
+		{"feat[]a]x", "feat]x", true},
+		{"feat[]a]x", "feataxx", false},
+		{"feat[]a]x", "featax", true},
+		{"feat[!]a]x", "feat]x", false},
+		{"feat[!]a]x", "featbx", true},
+		{"v[a-]", "v-", true},
+		{`v\*`, "v*", true},
+		{`v\*`, "v1", false},
+		{"v[", "v[", true},
+		{"a.b", "axb", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchBranchPattern(tt.pattern, tt.branch); got != tt.want {
+			t.Errorf("matchBranchPattern(%q, %q) = %t, want %t (regexp %s)", tt.pattern, tt.branch, got, tt.want, fnmatchToRegexp(tt.pattern))
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const diffHelp = `Show what changed between two snapshots.`
+
+type diffCommand struct{}
+
+func (cmd *diffCommand) Name() string      { return "diff" }
+func (cmd *diffCommand) Args() string      { return "[OPTIONS] OLD_SNAPSHOT NEW_SNAPSHOT" }
+func (cmd *diffCommand) ShortHelp() string { return diffHelp }
+func (cmd *diffCommand) LongHelp() string {
+	return diffHelp + `
+
+Snapshots are saved with -snapshot. Collaborators, deploy keys, hooks,
+protection rules and merge methods that were added, removed or changed are
+printed per repository, in text or json (-format).`
+}
+func (cmd *diffCommand) Hidden() bool { return false }
+
+func (cmd *diffCommand) Register(fs *flag.FlagSet) {}
+
+func (cmd *diffCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("diff takes exactly two snapshots: the old one and the new one")
+	}
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("unknown diff format %q (must be one of: %s, %s)", format, formatText, formatJSON)
+	}
+
+	before, err := loadSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := loadSnapshot(args[1])
+	if err != nil {
+		return err
+	}
+
+	d := diffSnapshots(before, after)
+	if format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	return d.writeText(os.Stdout)
+}
+
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// snapshotDiff holds what changed between two snapshots.
+type snapshotDiff struct {
+	Repositories []repoDiff `json:"repositories"`
+}
+
+// repoDiff holds what changed on a repository.
+type repoDiff struct {
+	Repository string `json:"repository"`
+	// Status is set to added or removed when the repository is only in one
+	// of the snapshots.
+	Status string `json:"status,omitempty"`
+	// Incomplete is set when the repository could not be fully audited in
+	// either snapshot, so some changes may be missing or spurious.
+	Incomplete bool     `json:"incomplete,omitempty"`
+	Changes    []change `json:"changes"`
+}
+
+// change is a single item of a repository that was added, removed or changed.
+type change struct {
+	// Kind is one of collaborator, deployKey, hook, protectionRule,
+	// mergeMethod or defaultBranch.
+	Kind   string `json:"kind"`
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	// Escalation is set when a collaborator's permission was raised.
+	Escalation bool `json:"escalation,omitempty"`
+}
+
+// diffItem is an item of a repository in a form that can be compared.
+type diffItem struct {
+	key   string
+	name  string
+	value string
+}
+
+// diffSnapshots compares the repositories of two snapshots, in the order of
+// the newer one followed by the repositories that were removed.
+func diffSnapshots(before, after *snapshot) snapshotDiff {
+	d := snapshotDiff{Repositories: []repoDiff{}}
+
+	old := map[string]*repoReport{}
+	for _, r := range before.Repositories {
+		old[r.Repository] = r
+	}
+	seen := map[string]bool{}
+
+	for _, r := range after.Repositories {
+		seen[r.Repository] = true
+		o, ok := old[r.Repository]
+		if !ok {
+			d.Repositories = append(d.Repositories, repoDiff{Repository: r.Repository, Status: changeAdded, Changes: []change{}})
+			continue
+		}
+		if rd := diffRepos(o, r); len(rd.Changes) > 0 {
+			d.Repositories = append(d.Repositories, rd)
+		}
+	}
+
+	for _, r := range before.Repositories {
+		if !seen[r.Repository] {
+			d.Repositories = append(d.Repositories, repoDiff{Repository: r.Repository, Status: changeRemoved, Changes: []change{}})
+		}
+	}
+
+	return d
+}
+
+// diffRepos compares two reports of the same repository.
+func diffRepos(before, after *repoReport) repoDiff {
+	d := repoDiff{
+		Repository: after.Repository,
+		Incomplete: len(before.Errors) > 0 || len(after.Errors) > 0,
+		Changes:    []change{},
+	}
+
+	collaborators := func(r *repoReport) []diffItem {
+		items := []diffItem{}
+		for _, c := range r.Collaborators {
+			items = append(items, diffItem{strings.ToLower(c.Login), c.Login, c.Permission})
+		}
+		return items
+	}
+	for _, c := range diffItems("collaborator", collaborators(before), collaborators(after)) {
+		if c.Action == changeChanged && permissionRank(c.After) > permissionRank(c.Before) {
+			c.Escalation = true
+		}
+		d.Changes = append(d.Changes, c)
+	}
+
+	keys := func(r *repoReport) []diffItem {
+		items := []diffItem{}
+		for _, k := range r.DeployKeys {
+			items = append(items, diffItem{k.ID, k.Title, fmt.Sprintf("ro:%t fingerprint:%s", k.ReadOnly, k.Fingerprint)})
+		}
+		return items
+	}
+	d.Changes = append(d.Changes, diffItems("deployKey", keys(before), keys(after))...)
+
+	hooks := func(r *repoReport) []diffItem {
+		items := []diffItem{}
+		for _, h := range r.Hooks {
+			// Legacy services have no target, fall back to the URL of the
+			// hook itself.
+			target := h.Target
+			if target == "" {
+				target = h.URL
+			}
+			events := append([]string{}, h.Events...)
+			sort.Strings(events)
+			items = append(items, diffItem{strconv.FormatInt(h.ID, 10), h.Name,
+				fmt.Sprintf("active:%t target:%s events:%s secret:%t", h.Active, target, strings.Join(events, ","), h.HasSecret)})
+		}
+		return items
+	}
+	d.Changes = append(d.Changes, diffItems("hook", hooks(before), hooks(after))...)
+
+	rules := func(r *repoReport) []diffItem {
+		items := []diffItem{}
+		for _, p := range r.ProtectionRules {
+			items = append(items, diffItem{p.Pattern, p.Pattern, p.settings()})
+		}
+		return items
+	}
+	d.Changes = append(d.Changes, diffItems("protectionRule", rules(before), rules(after))...)
+
+	mergeMethods := func(r *repoReport) []diffItem {
+		items := []diffItem{}
+		for _, m := range r.MergeMethods {
+			items = append(items, diffItem{m, m, ""})
+		}
+		return items
+	}
+	d.Changes = append(d.Changes, diffItems("mergeMethod", mergeMethods(before), mergeMethods(after))...)
+
+	if before.DefaultBranch != "" && before.DefaultBranch == after.DefaultBranch &&
+		before.DefaultBranchProtected != after.DefaultBranchProtected {
+		d.Changes = append(d.Changes, change{
+			Kind:   "defaultBranch",
+			Action: changeChanged,
+			Name:   after.DefaultBranch,
+			Before: fmt.Sprintf("protected:%t", before.DefaultBranchProtected),
+			After:  fmt.Sprintf("protected:%t", after.DefaultBranchProtected),
+		})
+	}
+
+	return d
+}
+
+// diffItems returns the items that were added or changed, in the order of
+// after, followed by the items that were removed.
+func diffItems(kind string, before, after []diffItem) []change {
+	changes := []change{}
+
+	old := map[string]diffItem{}
+	for _, i := range before {
+		old[i.key] = i
+	}
+	seen := map[string]bool{}
+
+	for _, i := range after {
+		seen[i.key] = true
+		o, ok := old[i.key]
+		switch {
+		case !ok:
+			changes = append(changes, change{Kind: kind, Action: changeAdded, Name: i.name, After: i.value})
+		case o.value != i.value:
+			changes = append(changes, change{Kind: kind, Action: changeChanged, Name: i.name, Before: o.value, After: i.value})
+		}
+	}
+
+	for _, i := range before {
+		if !seen[i.key] {
+			changes = append(changes, change{Kind: kind, Action: changeRemoved, Name: i.name, Before: i.value})
+		}
+	}
+
+	return changes
+}
+
+// writeText prints the diff in the same layout as the text report.
+func (d snapshotDiff) writeText(w io.Writer) error {
+	if len(d.Repositories) < 1 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	for _, r := range d.Repositories {
+		output := fmt.Sprintf("%s -> %s\n", r.Repository, r.Status)
+		if r.Incomplete {
+			output += "\t(incomplete audit, some changes may be missing or spurious)\n"
+		}
+		for _, c := range r.Changes {
+			output += "\t" + c.String() + "\n"
+		}
+		if _, err := fmt.Fprintf(w, "%s--\n\n", output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c change) String() string {
+	switch c.Action {
+	case changeAdded:
+		s := fmt.Sprintf("+ %s %s", c.Kind, c.Name)
+		if c.After != "" {
+			s += ": " + c.After
+		}
+		return s
+	case changeRemoved:
+		s := fmt.Sprintf("- %s %s", c.Kind, c.Name)
+		if c.Before != "" {
+			s += ": " + c.Before
+		}
+		return s
+	}
+	s := fmt.Sprintf("~ %s %s: %s -> %s", c.Kind, c.Name, c.Before, c.After)
+	if c.Escalation {
+		s += " (escalation)"
+	}
+	return s
+}
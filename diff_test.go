@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	hook := hookReport{
+		ID:        1234,
+		Name:      "web",
+		Active:    true,
+		URL:       "https://api.github.com/repos/genuinetools/audit/hooks/1234",
+		Target:    "https://ci.example.com/hook",
+		Host:      "ci.example.com",
+		Events:    []string{"push", "pull_request"},
+		HasSecret: true,
+	}
+	before := &snapshot{Repositories: []*repoReport{
+		{
+			Repository: "genuinetools/audit",
+			Collaborators: []collaboratorReport{
+				{Login: "jessfraz", Permission: "WRITE"},
+				{Login: "sam", Permission: "ADMIN"},
+				{Login: "kim", Permission: "READ"},
+			},
+			DeployKeys: []deployKeyReport{
+				{ID: "DK_1", Title: "deploy", ReadOnly: true, Fingerprint: "SHA256:old"},
+				{ID: "DK_2", Title: "ci", ReadOnly: true, Fingerprint: "SHA256:ci"},
+			},
+			Hooks:        []hookReport{hook},
+			MergeMethods: []string{"mergeCommit"},
+		},
+		{Repository: "genuinetools/img"},
+		{Repository: "genuinetools/reg"},
+	}}
+
+	retargeted := hook
+	retargeted.Target = "https://ci.attacker.example/hook"
+	retargeted.Host = "ci.attacker.example"
+	retargeted.Events = []string{"pull_request", "push", "release"}
+	retargeted.HasSecret = false
+	after := &snapshot{Repositories: []*repoReport{
+		{
+			Repository: "genuinetools/audit",
+			Collaborators: []collaboratorReport{
+				{Login: "JessFraz", Permission: "ADMIN"},
+				{Login: "sam", Permission: "WRITE"},
+				{Login: "kim", Permission: "READ"},
+				{Login: "lee", Permission: "READ"},
+			},
+			DeployKeys: []deployKeyReport{
+				{ID: "DK_1", Title: "deploy", ReadOnly: true, Fingerprint: "SHA256:new"},
+				{ID: "DK_2", Title: "ci", ReadOnly: true, Fingerprint: "SHA256:ci"},
+			},
+			Hooks:        []hookReport{retargeted},
+			MergeMethods: []string{"squash"},
+			Errors:       []string{"listing hooks failed"},
+		},
+		{Repository: "genuinetools/img"},
+		{Repository: "genuinetools/bane"},
+	}}
+
+	want := snapshotDiff{Repositories: []repoDiff{
+		{
+			Repository: "genuinetools/audit",
+			Incomplete: true,
+			Changes: []change{
+				{Kind: "collaborator", Action: changeChanged, Name: "JessFraz", Before: "WRITE", After: "ADMIN", Escalation: true},
+				{Kind: "collaborator", Action: changeChanged, Name: "sam", Before: "ADMIN", After: "WRITE"},
+				{Kind: "collaborator", Action: changeAdded, Name: "lee", After: "READ"},
+				{Kind: "deployKey", Action: changeChanged, Name: "deploy", Before: "ro:true fingerprint:SHA256:old", After: "ro:true fingerprint:SHA256:new"},
+				{
+					Kind:   "hook",
+					Action: changeChanged,
+					Name:   "web",
+					Before: "active:true target:https://ci.example.com/hook events:pull_request,push secret:true",
+					After:  "active:true target:https://ci.attacker.example/hook events:pull_request,push,release secret:false",
+				},
+				{Kind: "mergeMethod", Action: changeAdded, Name: "squash"},
+				{Kind: "mergeMethod", Action: changeRemoved, Name: "mergeCommit"},
+			},
+		},
+		{Repository: "genuinetools/bane", Status: changeAdded, Changes: []change{}},
+		{Repository: "genuinetools/reg", Status: changeRemoved, Changes: []change{}},
+	}}
+
+	got := diffSnapshots(before, after)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSnapshots =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiffHookEventOrder(t *testing.T) {
+	before := &repoReport{Repository: "genuinetools/audit", Hooks: []hookReport{
+		{ID: 1, Name: "web", Target: "https://ci.example.com/hook", Events: []string{"push", "pull_request"}},
+	}}
+	after := &repoReport{Repository: "genuinetools/audit", Hooks: []hookReport{
+		{ID: 1, Name: "web", Target: "https://ci.example.com/hook", Events: []string{"pull_request", "push"}},
+	}}
+
+	if d := diffRepos(before, after); len(d.Changes) > 0 {
+		t.Errorf("reordered events are reported as changes: %+v", d.Changes)
+	}
+}
+
+func TestDiffAddedAndRemovedItems(t *testing.T) {
+	before := &repoReport{
+		Repository:      "genuinetools/audit",
+		DefaultBranch:   "master",
+		DeployKeys:      []deployKeyReport{{ID: "DK_1", Title: "deploy", ReadOnly: true, Fingerprint: "SHA256:deploy"}},
+		ProtectionRules: []protectionRuleReport{{Pattern: "master"}},
+	}
+	after := &repoReport{
+		Repository:             "genuinetools/audit",
+		DefaultBranch:          "master",
+		DefaultBranchProtected: true,
+		Hooks:                  []hookReport{{ID: 1, Name: "travis", Active: true, URL: "https://api.github.com/repos/genuinetools/audit/hooks/1"}},
+	}
+
+	var b bytes.Buffer
+	d := snapshotDiff{Repositories: []repoDiff{diffRepos(before, after)}}
+	if err := d.writeText(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	// The status of a repository in both snapshots is empty.
+	want := "genuinetools/audit -> \n" + `	- deployKey deploy: ro:true fingerprint:SHA256:deploy
+	+ hook travis: active:true target:https://api.github.com/repos/genuinetools/audit/hooks/1 events: secret:false
+	- protectionRule master: ` + (protectionRuleReport{Pattern: "master"}).settings() + `
+	~ defaultBranch master: protected:false -> protected:true
+--
+
+`
+	if got := b.String(); got != want {
+		t.Errorf("text diff =\n%s\nwant\n%s", got, want)
+	}
+}
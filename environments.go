@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The deployment branch policies of an environment.
+	branchPolicyAll       = "all"
+	branchPolicyProtected = "protected"
+	branchPolicyCustom    = "custom"
+)
+
+// defaultProductionEnvs are the words that mark an environment as production
+// when -production-env is not set.
+var defaultProductionEnvs = []string{"prod", "production", "live"}
+
+// The following types are the environments of a repository and their
+// protection rules.
+
+type repoEnvironments struct {
+	TotalCount   int               `json:"total_count"`
+	Environments []repoEnvironment `json:"environments"`
+}
+
+type repoEnvironment struct {
+	Name            string                  `json:"name"`
+	HTMLURL         string                  `json:"html_url"`
+	ProtectionRules []environmentProtection `json:"protection_rules"`
+	// DeploymentBranchPolicy is nil when any branch can deploy.
+	DeploymentBranchPolicy *struct {
+		ProtectedBranches    bool `json:"protected_branches"`
+		CustomBranchPolicies bool `json:"custom_branch_policies"`
+	} `json:"deployment_branch_policy"`
+}
+
+// environmentProtection is a protection rule of an environment, Type is
+// required_reviewers, wait_timer or branch_policy.
+type environmentProtection struct {
+	Type      string `json:"type"`
+	WaitTimer int    `json:"wait_timer"`
+	Reviewers []struct {
+		Type     string `json:"type"`
+		Reviewer struct {
+			Login string `json:"login"`
+			Slug  string `json:"slug"`
+		} `json:"reviewer"`
+	} `json:"reviewers"`
+}
+
+type deploymentBranchPolicies struct {
+	TotalCount     int `json:"total_count"`
+	BranchPolicies []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"branch_policies"`
+}
+
+// auditEnvironments adds the deployment environments of the repository,
+// their protection rules and the names of their secrets to the report. An
+// environment whose rules or secrets could not be listed is still reported,
+// and what could not be listed is recorded in the report.
+func (a *auditor) auditEnvironments(ctx context.Context, repo ghrepo, r *repoReport) error {
+	base := fmt.Sprintf("repos/%s/%s/environments", repo.Owner.Login, repo.Name)
+
+	logrus.Debugf("Executing REST query to list environments for %s", repo.NameWithOwner)
+	envs := []repoEnvironment{}
+	for page := 1; page != 0; {
+		var data repoEnvironments
+		resp, err := a.restGet(ctx, fmt.Sprintf("%s?per_page=100&page=%d", base, page), &data)
+		if err != nil {
+			if isRateLimit(err) {
+				return err
+			}
+			r.addError("environments", err)
+			return nil
+		}
+		envs = append(envs, data.Environments...)
+		page = resp.NextPage
+	}
+
+	for _, env := range envs {
+		path := base + "/" + url.PathEscape(env.Name)
+		report := environmentReport{
+			Name:              env.Name,
+			URL:               env.HTMLURL,
+			Production:        isProduction(env.Name, a.productionEnvs),
+			RequiredReviewers: []string{},
+			BranchPolicy:      branchPolicyAll,
+			Secrets:           []string{},
+		}
+
+		for _, p := range env.ProtectionRules {
+			switch p.Type {
+			case "required_reviewers":
+				for _, r := range p.Reviewers {
+					if r.Type == "Team" {
+						report.RequiredReviewers = append(report.RequiredReviewers, repo.Owner.Login+"/"+r.Reviewer.Slug)
+					} else {
+						report.RequiredReviewers = append(report.RequiredReviewers, r.Reviewer.Login)
+					}
+				}
+			case "wait_timer":
+				report.WaitTimer = p.WaitTimer
+			}
+		}
+
+		if policy := env.DeploymentBranchPolicy; policy != nil {
+			switch {
+			case policy.ProtectedBranches:
+				report.BranchPolicy = branchPolicyProtected
+			case policy.CustomBranchPolicies:
+				report.BranchPolicy = branchPolicyCustom
+
+				logrus.Debugf("Executing REST query to list deployment branch policies of environment %s for %s", env.Name, repo.NameWithOwner)
+				var data deploymentBranchPolicies
+				if _, err := a.restGet(ctx, path+"/deployment-branch-policies?per_page=100", &data); err != nil {
+					if isRateLimit(err) {
+						return err
+					}
+					r.addError("deployment branch policies of environment "+env.Name, err)
+				}
+				for _, b := range data.BranchPolicies {
+					if b.Type == "tag" {
+						report.BranchPatterns = append(report.BranchPatterns, "tag:"+b.Name)
+					} else {
+						report.BranchPatterns = append(report.BranchPatterns, b.Name)
+					}
+				}
+			}
+		}
+
+		logrus.Debugf("Executing REST query to list secrets of environment %s for %s", env.Name, repo.NameWithOwner)
+		secrets, err := a.listSecretNames(ctx, path+"/secrets")
+		if err != nil {
+			if isRateLimit(err) {
+				return err
+			}
+			r.addError("secrets of environment "+env.Name, err)
+		} else {
+			report.Secrets = secrets
+		}
+
+		r.Environments = append(r.Environments, report)
+	}
+
+	return nil
+}
+
+// isProduction reports whether the name of an environment marks it as
+// production: one of its words, split on '-', '_', '.', '/' and spaces, is
+// one of words, and is not preceded by "non" or "pre" as in non-prod or
+// pre-production.
+func isProduction(name string, words []string) bool {
+	tokens := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return strings.ContainsRune("-_./ ", r)
+	})
+	for i, t := range tokens {
+		if i > 0 && (tokens[i-1] == "non" || tokens[i-1] == "pre") {
+			continue
+		}
+		for _, w := range words {
+			if t == strings.ToLower(w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// environmentFindings runs the environment rules against a repository report.
+func environmentFindings(r *repoReport) []finding {
+	findings := []finding{}
+
+	for _, e := range r.Environments {
+		if !e.Production {
+			continue
+		}
+		if len(e.RequiredReviewers) < 1 {
+			findings = append(findings, newFinding(ruleEnvironmentNoReviewers, r.Repository, e.Name,
+				fmt.Sprintf("environment %s can be deployed to without a review", e.Name)))
+		}
+		if e.BranchPolicy == branchPolicyAll {
+			findings = append(findings, newFinding(ruleEnvironmentAnyBranch, r.Repository, e.Name,
+				fmt.Sprintf("environment %s can be deployed to from any branch", e.Name)))
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestIsProduction(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  bool
+	}{
+		{"production", defaultProductionEnvs, true},
+		{"Production", defaultProductionEnvs, true},
+		{"prod", defaultProductionEnvs, true},
+		{"prod-eu", defaultProductionEnvs, true},
+		{"eu_prod", defaultProductionEnvs, true},
+		{"github-pages/live", defaultProductionEnvs, true},
+		{"Live site", defaultProductionEnvs, true},
+		{"non-prod", defaultProductionEnvs, false},
+		{"pre-production", defaultProductionEnvs, false},
+		{"pre.prod", defaultProductionEnvs, false},
+		{"preprod", defaultProductionEnvs, false},
+		{"delivery", defaultProductionEnvs, false},
+		{"product-docs", defaultProductionEnvs, false},
+		{"olive", defaultProductionEnvs, false},
+		{"staging", defaultProductionEnvs, false},
+		{"prd-us", []string{"prd"}, true},
+		{"prod", []string{"prd"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := isProduction(tt.name, tt.words); got != tt.want {
+			t.Errorf("isProduction(%q, %v) = %t, want %t", tt.name, tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestEnvironmentFindings(t *testing.T) {
+	r := newRepoReport(ghrepo{NameWithOwner: "genuinetools/audit"})
+	r.Environments = []environmentReport{
+		{Name: "production", Production: true, RequiredReviewers: []string{}, BranchPolicy: branchPolicyAll},
+		{Name: "live", Production: true, RequiredReviewers: []string{"jess"}, BranchPolicy: branchPolicyProtected},
+		{Name: "staging", RequiredReviewers: []string{}, BranchPolicy: branchPolicyAll},
+	}
+
+	got := []string{}
+	for _, f := range environmentFindings(r) {
+		got = append(got, f.RuleID+":"+f.Identifier)
+	}
+	want := []string{
+		ruleEnvironmentNoReviewers + ":production",
+		ruleEnvironmentAnyBranch + ":production",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %v, want %v", got, want)
+	}
+}
+
+func TestAuditEnvironmentsSecretsNotVisible(t *testing.T) {
+	a, done := newTestAuditor(map[string]testResponse{
+		"/repos/genuinetools/audit/environments":                    {body: `{"total_count": 1, "environments": [{"name": "production"}]}`},
+		"/repos/genuinetools/audit/environments/production/secrets": {http.StatusNotFound, `{"message": "Not Found"}`},
+	})
+	defer done()
+
+	repo := ghrepo{Name: "audit", NameWithOwner: "genuinetools/audit"}
+	repo.Owner.Login = "genuinetools"
+	report := newRepoReport(repo)
+	if err := a.auditEnvironments(context.Background(), repo, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Environments) != 1 || !report.Environments[0].Production {
+		t.Errorf("environments = %+v, want the production environment", report.Environments)
+	}
+	if want := []string{"secrets of environment production"}; !reflect.DeepEqual(report.NotVisible, want) {
+		t.Errorf("not visible = %v, want %v", report.NotVisible, want)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("errors = %v, want none", report.Errors)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// severity describes how serious a finding is.
+type severity string
+
+const (
+	severityLow      severity = "low"
+	severityMedium   severity = "medium"
+	severityHigh     severity = "high"
+	severityCritical severity = "critical"
+)
+
+// rank returns the position of the severity on the scale, higher is worse.
+func (s severity) rank() int {
+	switch s {
+	case severityLow:
+		return 1
+	case severityMedium:
+		return 2
+	case severityHigh:
+		return 3
+	case severityCritical:
+		return 4
+	}
+	return 0
+}
+
+// finding is a single problem found while auditing a repository or an
+// organization.
+type finding struct {
+	RuleID   string   `json:"ruleId"`
+	Severity severity `json:"severity"`
+	// Repository is the repository, or organization, the finding is on.
+	Repository string `json:"repository"`
+	// Identifier is the item the finding is about, for example a hook or
+	// deploy key id, or a branch name.
+	Identifier string `json:"identifier"`
+	Message    string `json:"message"`
+}
+
+// fingerprint returns a stable identifier for the finding across runs.
+func (f finding) fingerprint() string {
+	return fmt.Sprintf("%s:%s:%s", f.Repository, f.RuleID, f.Identifier)
+}
+
+// MarshalJSON adds the fingerprint to the finding so it can be suppressed.
+func (f finding) MarshalJSON() ([]byte, error) {
+	type plain finding
+	return json.Marshal(struct {
+		plain
+		Fingerprint string `json:"fingerprint"`
+	}{plain(f), f.fingerprint()})
+}
+
+// rule describes a check the audit performs.
+type rule struct {
+	ID          string
+	Name        string
+	Description string
+	Severity    severity
+}
+
+const (
+	ruleDeployKeyWriteAccess      = "deploy-key-write-access"
+	ruleDeployKeyWeak             = "deploy-key-weak"
+	ruleDeployKeyReused           = "deploy-key-reused"
+	ruleHookInactive              = "hook-inactive"
+	ruleHookInsecureURL           = "hook-insecure-url"
+	ruleHookInsecureSSL           = "hook-insecure-ssl"
+	ruleHookNoSecret              = "hook-no-secret"
+	ruleHookCredentialsInURL      = "hook-credentials-in-url"
+	ruleHookWildcardEvents        = "hook-wildcard-events"
+	ruleHookFailing               = "hook-failing"
+	ruleHookNeverDelivered        = "hook-never-delivered"
+	ruleHookUnapprovedHost        = "hook-unapproved-host"
+	ruleHookUnapprovedPrivatePush = "hook-unapproved-host-private-push"
+	ruleDefaultBranchUnprotected  = "default-branch-unprotected"
+	ruleProtectionForcePushes     = "protection-allows-force-pushes"
+	ruleProtectionDeletions       = "protection-allows-deletions"
+	ruleProtectionAdminsExempt    = "protection-admins-exempt"
+	ruleDirectGrantRedundant      = "direct-grant-redundant"
+	ruleDirectGrantExceedsTeams   = "direct-grant-exceeds-teams"
+	ruleOrgTwoFactorNotRequired   = "org-2fa-not-required"
+	ruleOrgMemberWithoutTwoFactor = "org-member-2fa-disabled"
+	ruleOrgDefaultPermission      = "org-default-permission-permissive"
+	ruleOrgMembersCreatePublic    = "org-members-can-create-public-repos"
+	ruleOrgMembersForkPrivate     = "org-members-can-fork-private-repos"
+	ruleActionsTokenWrite         = "actions-token-write"
+	ruleActionsApprovePRs         = "actions-can-approve-prs"
+	ruleActionsForkApproval       = "actions-fork-pr-approval-permissive"
+	ruleWorkflowUntrustedCheckout = "workflow-untrusted-checkout"
+	ruleWorkflowScriptInjection   = "workflow-script-injection"
+	ruleWorkflowUnpinnedAction    = "workflow-unpinned-action"
+	ruleWorkflowWriteAll          = "workflow-write-all"
+	ruleEnvironmentNoReviewers    = "environment-no-reviewers"
+	ruleEnvironmentAnyBranch      = "environment-any-branch"
+	ruleAppWriteAllRepos          = "app-write-all-repos"
+	ruleOAuthRestrictionsDisabled = "org-oauth-restrictions-disabled"
+)
+
+// auditRules holds every rule keyed by its id: the built-in rules and the
+// rules of the policy once it is loaded.
+// The ids of built-in rules are stable and must not be changed once released.
+var auditRules = map[string]rule{
+	ruleDeployKeyWriteAccess: {
+		ID:          ruleDeployKeyWriteAccess,
+		Name:        "DeployKeyWriteAccess",
+		Description: "Deploy key has write access to the repository.",
+		Severity:    severityHigh,
+	},
+	ruleDeployKeyWeak: {
+		ID:          ruleDeployKeyWeak,
+		Name:        "DeployKeyWeak",
+		Description: "Deploy key is a DSA key or an RSA key shorter than 2048 bits.",
+		Severity:    severityHigh,
+	},
+	ruleDeployKeyReused: {
+		ID:          ruleDeployKeyReused,
+		Name:        "DeployKeyReused",
+		Description: "Deploy key is used on more than one repository.",
+		Severity:    severityMedium,
+	},
+	ruleHookInactive: {
+		ID:          ruleHookInactive,
+		Name:        "HookInactive",
+		Description: "Webhook is configured but inactive.",
+		Severity:    severityLow,
+	},
+	ruleHookInsecureURL: {
+		ID:          ruleHookInsecureURL,
+		Name:        "HookInsecureURL",
+		Description: "Webhook delivers its payloads over plain http.",
+		Severity:    severityHigh,
+	},
+	ruleHookInsecureSSL: {
+		ID:          ruleHookInsecureSSL,
+		Name:        "HookInsecureSSL",
+		Description: "Webhook does not verify the certificate of the host it delivers to.",
+		Severity:    severityHigh,
+	},
+	ruleHookNoSecret: {
+		ID:          ruleHookNoSecret,
+		Name:        "HookNoSecret",
+		Description: "Webhook has no secret, so its payloads cannot be verified.",
+		Severity:    severityMedium,
+	},
+	ruleHookCredentialsInURL: {
+		ID:          ruleHookCredentialsInURL,
+		Name:        "HookCredentialsInURL",
+		Description: "Webhook URL has credentials embedded in it.",
+		Severity:    severityHigh,
+	},
+	ruleHookWildcardEvents: {
+		ID:          ruleHookWildcardEvents,
+		Name:        "HookWildcardEvents",
+		Description: "Webhook is subscribed to every event.",
+		Severity:    severityLow,
+	},
+	ruleHookFailing: {
+		ID:          ruleHookFailing,
+		Name:        "HookFailing",
+		Description: "Webhook deliveries are failing, the service it delivers to may be gone.",
+		Severity:    severityMedium,
+	},
+	ruleHookNeverDelivered: {
+		ID:          ruleHookNeverDelivered,
+		Name:        "HookNeverDelivered",
+		Description: "Webhook has never delivered a payload.",
+		Severity:    severityLow,
+	},
+	ruleHookUnapprovedHost: {
+		ID:          ruleHookUnapprovedHost,
+		Name:        "HookUnapprovedHost",
+		Description: "Webhook delivers to a host that is not in the allowlist.",
+		Severity:    severityMedium,
+	},
+	ruleHookUnapprovedPrivatePush: {
+		ID:          ruleHookUnapprovedPrivatePush,
+		Name:        "HookUnapprovedHostPrivatePush",
+		Description: "Webhook sends the push events of a private repository to a host that is not in the allowlist.",
+		Severity:    severityCritical,
+	},
+	ruleDefaultBranchUnprotected: {
+		ID:          ruleDefaultBranchUnprotected,
+		Name:        "DefaultBranchUnprotected",
+		Description: "Default branch is not covered by any branch protection rule.",
+		Severity:    severityHigh,
+	},
+	ruleProtectionForcePushes: {
+		ID:          ruleProtectionForcePushes,
+		Name:        "ProtectionAllowsForcePushes",
+		Description: "Branch protection rule allows force pushes.",
+		Severity:    severityHigh,
+	},
+	ruleProtectionDeletions: {
+		ID:          ruleProtectionDeletions,
+		Name:        "ProtectionAllowsDeletions",
+		Description: "Branch protection rule allows the protected branches to be deleted.",
+		Severity:    severityMedium,
+	},
+	ruleProtectionAdminsExempt: {
+		ID:          ruleProtectionAdminsExempt,
+		Name:        "ProtectionAdminsExempt",
+		Description: "Branch protection rule is not enforced for administrators.",
+		Severity:    severityLow,
+	},
+	ruleDirectGrantRedundant: {
+		ID:          ruleDirectGrantRedundant,
+		Name:        "DirectGrantRedundant",
+		Description: "Collaborator is granted access directly although their teams or organization already grant as much.",
+		Severity:    severityLow,
+	},
+	ruleDirectGrantExceedsTeams: {
+		ID:          ruleDirectGrantExceedsTeams,
+		Name:        "DirectGrantExceedsTeams",
+		Description: "Collaborator is granted more access directly than through their teams.",
+		Severity:    severityMedium,
+	},
+	ruleActionsTokenWrite: {
+		ID:          ruleActionsTokenWrite,
+		Name:        "ActionsTokenWrite",
+		Description: "Workflows get a GITHUB_TOKEN with write access by default.",
+		Severity:    severityMedium,
+	},
+	ruleActionsApprovePRs: {
+		ID:          ruleActionsApprovePRs,
+		Name:        "ActionsCanApprovePRs",
+		Description: "Workflows can approve pull requests.",
+		Severity:    severityMedium,
+	},
+	ruleActionsForkApproval: {
+		ID:          ruleActionsForkApproval,
+		Name:        "ActionsForkPRApprovalPermissive",
+		Description: "Workflows of pull requests from forks run without approval for most outside contributors.",
+		Severity:    severityLow,
+	},
+	ruleWorkflowUntrustedCheckout: {
+		ID:          ruleWorkflowUntrustedCheckout,
+		Name:        "WorkflowUntrustedCheckout",
+		Description: "Workflow triggered by pull_request_target or workflow_run checks out the code of the pull request.",
+		Severity:    severityCritical,
+	},
+	ruleWorkflowScriptInjection: {
+		ID:          ruleWorkflowScriptInjection,
+		Name:        "WorkflowScriptInjection",
+		Description: "Workflow interpolates untrusted input into a script.",
+		Severity:    severityHigh,
+	},
+	ruleWorkflowUnpinnedAction: {
+		ID:          ruleWorkflowUnpinnedAction,
+		Name:        "WorkflowUnpinnedAction",
+		Description: "Workflow uses a third-party action that is not pinned to a full commit SHA.",
+		Severity:    severityMedium,
+	},
+	ruleWorkflowWriteAll: {
+		ID:          ruleWorkflowWriteAll,
+		Name:        "WorkflowWriteAll",
+		Description: "Workflow or job has permissions: write-all.",
+		Severity:    severityHigh,
+	},
+	ruleEnvironmentNoReviewers: {
+		ID:          ruleEnvironmentNoReviewers,
+		Name:        "EnvironmentNoReviewers",
+		Description: "Production environment does not require a reviewer to approve deployments.",
+		Severity:    severityHigh,
+	},
+	ruleEnvironmentAnyBranch: {
+		ID:          ruleEnvironmentAnyBranch,
+		Name:        "EnvironmentAnyBranch",
+		Description: "Production environment can be deployed to from any branch.",
+		Severity:    severityMedium,
+	},
+	ruleOrgTwoFactorNotRequired: {
+		ID:          ruleOrgTwoFactorNotRequired,
+		Name:        "OrgTwoFactorNotRequired",
+		Description: "Organization does not require two-factor authentication.",
+		Severity:    severityMedium,
+	},
+	ruleOrgMemberWithoutTwoFactor: {
+		ID:          ruleOrgMemberWithoutTwoFactor,
+		Name:        "OrgMemberTwoFactorDisabled",
+		Description: "Organization member has two-factor authentication disabled.",
+		Severity:    severityHigh,
+	},
+	ruleOrgDefaultPermission: {
+		ID:          ruleOrgDefaultPermission,
+		Name:        "OrgDefaultPermissionPermissive",
+		Description: "Organization gives every member write or admin access to every repository.",
+		Severity:    severityMedium,
+	},
+	ruleOrgMembersCreatePublic: {
+		ID:          ruleOrgMembersCreatePublic,
+		Name:        "OrgMembersCanCreatePublicRepos",
+		Description: "Organization members can create public repositories.",
+		Severity:    severityLow,
+	},
+	ruleOrgMembersForkPrivate: {
+		ID:          ruleOrgMembersForkPrivate,
+		Name:        "OrgMembersCanForkPrivateRepos",
+		Description: "Organization members can fork private repositories.",
+		Severity:    severityMedium,
+	},
+	ruleAppWriteAllRepos: {
+		ID:          ruleAppWriteAllRepos,
+		Name:        "AppWriteAllRepos",
+		Description: "GitHub App is installed on every repository with write or admin permissions.",
+		Severity:    severityHigh,
+	},
+	ruleOAuthRestrictionsDisabled: {
+		ID:          ruleOAuthRestrictionsDisabled,
+		Name:        "OrgOAuthRestrictionsDisabled",
+		Description: "Organization does not restrict the OAuth Apps that can access it.",
+		Severity:    severityMedium,
+	},
+}
+
+// registerRules adds the rules to the known rules.
+func registerRules(rules []rule) {
+	for _, r := range rules {
+		auditRules[r.ID] = r
+	}
+}
+
+// newFinding returns a finding for the built-in rule with the given id on the
+// repository, or organization.
+func newFinding(ruleID string, repository, identifier, message string) finding {
+	return finding{
+		RuleID:     ruleID,
+		Severity:   auditRules[ruleID].Severity,
+		Repository: repository,
+		Identifier: identifier,
+		Message:    message,
+	}
+}
+
+// repoFindings runs the built-in rules against a repository report.
+func repoFindings(r *repoReport) []finding {
+	findings := []finding{}
+
+	for _, c := range r.Collaborators {
+		if c.RedundantDirectGrant {
+			findings = append(findings, newFinding(ruleDirectGrantRedundant, r.Repository, c.Login,
+				fmt.Sprintf("%s has a redundant direct %s grant", c.Login, c.directPermission())))
+		}
+		if c.DirectGrantExceedsTeams {
+			findings = append(findings, newFinding(ruleDirectGrantExceedsTeams, r.Repository, c.Login,
+				fmt.Sprintf("%s is granted %s directly, more than their teams grant", c.Login, c.directPermission())))
+		}
+	}
+
+	for _, k := range r.DeployKeys {
+		if !k.ReadOnly {
+			findings = append(findings, newFinding(ruleDeployKeyWriteAccess, r.Repository, k.ID,
+				fmt.Sprintf("deploy key %q has write access", k.Title)))
+		}
+		if k.Weak {
+			findings = append(findings, newFinding(ruleDeployKeyWeak, r.Repository, k.ID,
+				fmt.Sprintf("deploy key %q is a weak %d bits %s key", k.Title, k.Bits, k.Type)))
+		}
+	}
+
+	findings = append(findings, hookFindings(r.Repository, r.Private, r.Hooks)...)
+	findings = append(findings, actionsFindings(r)...)
+	findings = append(findings, workflowFindings(r)...)
+	findings = append(findings, environmentFindings(r)...)
+
+	for _, p := range r.ProtectionRules {
+		if p.AllowsForcePushes {
+			who := "by everyone with write access"
+			if p.RestrictsPushes {
+				who = "by " + strings.Join(p.PushAllowances, ", ")
+			}
+			findings = append(findings, newFinding(ruleProtectionForcePushes, r.Repository, p.Pattern,
+				fmt.Sprintf("protection rule %s allows force pushes %s", p.Pattern, who)))
+		}
+		if p.AllowsDeletions {
+			findings = append(findings, newFinding(ruleProtectionDeletions, r.Repository, p.Pattern,
+				fmt.Sprintf("protection rule %s allows deletions", p.Pattern)))
+		}
+		if !p.EnforceForAdmins {
+			findings = append(findings, newFinding(ruleProtectionAdminsExempt, r.Repository, p.Pattern,
+				fmt.Sprintf("protection rule %s is not enforced for administrators", p.Pattern)))
+		}
+	}
+
+	if r.DefaultBranch != "" && !r.DefaultBranchProtected {
+		findings = append(findings, newFinding(ruleDefaultBranchUnprotected, r.Repository, r.DefaultBranch,
+			fmt.Sprintf("default branch %s is not covered by any branch protection rule", r.DefaultBranch)))
+	}
+
+	return findings
+}
@@ -3,8 +3,13 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // GQLRequest is the GraphQL request containing Query and Variables
@@ -32,6 +37,86 @@ func (e GQLError) Error() string {
 	return e.Message
 }
 
+// GQLErrors is the list of errors returned alongside (partial) data by the
+// GraphQL server.
+type GQLErrors []GQLError
+
+// Error returns the messages of all the errors
+func (e GQLErrors) Error() string {
+	msgs := []string{}
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// byRepository groups the errors by the index of the repository they belong
+// to, based on their path, and describes which field failed. Errors that
+// cannot be attributed to a repository are returned on their own.
+func (e GQLErrors) byRepository() (map[int][]string, GQLErrors) {
+	var (
+		attributed   = map[int][]string{}
+		unattributed GQLErrors
+	)
+	for _, err := range e {
+		index, field, ok := repositoryPath(err.Path)
+		if !ok {
+			unattributed = append(unattributed, err)
+			continue
+		}
+		msg := err.Message
+		if field != "" {
+			msg = field + ": " + msg
+		}
+		attributed[index] = append(attributed[index], msg)
+	}
+	return attributed, unattributed
+}
+
+// repositoryPath finds the repository in an error path, either a single
+// "repository" or a node of a "repositories" connection, and returns its index
+// along with the path of the field below it.
+func repositoryPath(path []interface{}) (int, string, bool) {
+	for i, p := range path {
+		switch p {
+		case "repository":
+			return 0, joinPath(path[i+1:]), true
+		case "repositories":
+			if i+2 < len(path) && path[i+1] == "nodes" {
+				// JSON numbers are decoded as float64.
+				if index, ok := path[i+2].(float64); ok {
+					return int(index), joinPath(path[i+3:]), true
+				}
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// joinPath joins the field names of an error path, skipping list indexes.
+func joinPath(path []interface{}) string {
+	fields := []string{}
+	for _, p := range path {
+		if f, ok := p.(string); ok {
+			fields = append(fields, f)
+		}
+	}
+	return strings.Join(fields, ".")
+}
+
+// GQLHTTPError is returned when the GraphQL endpoint responds with a non-2xx
+// status code.
+type GQLHTTPError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+// Error returns the status and body of the response
+func (e *GQLHTTPError) Error() string {
+	return fmt.Sprintf("graphql request failed with %s: %s", e.Status, e.Body)
+}
+
 // GQLErrorLocation is the location of error in the query string
 type GQLErrorLocation struct {
 	Line   int `json:"line"`
@@ -46,17 +131,23 @@ type GQLClient struct {
 }
 
 // NewGQLClient returns a GQLClient for given endpoint and headers
-func NewGQLClient(endpoint string, headers map[string]string) *GQLClient {
+// If client is nil, http.DefaultClient is used.
+func NewGQLClient(endpoint string, client *http.Client, headers map[string]string) *GQLClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
 	return &GQLClient{
 		Endpoint: endpoint,
 		Headers:  headers,
-		client:   &http.Client{},
+		client:   client,
 	}
 }
 
 // Execute executes the GQLRequest r using the GQLClient c and returns an error
-// Response data and errors can be unmarshalled to the passed interfaces
-func (c *GQLClient) Execute(r GQLRequest, data interface{}, errors interface{}) error {
+// Response data is unmarshalled to the passed interface. If the server
+// returned errors alongside the data, the data is still unmarshalled and the
+// errors are returned as GQLErrors.
+func (c *GQLClient) Execute(r GQLRequest, data interface{}) error {
 	payload, err := json.Marshal(r)
 	if err != nil {
 		return err
@@ -74,21 +165,39 @@ func (c *GQLClient) Execute(r GQLRequest, data interface{}, errors interface{})
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return &GQLHTTPError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+
 	var response GQLResponse
 	err = json.NewDecoder(res.Body).Decode(&response)
 	if err != nil {
 		return err
 	}
 
-	err = json.Unmarshal(*response.Data, data)
-	if err != nil {
-		return err
+	if response.Data != nil {
+		err = json.Unmarshal(*response.Data, data)
+		if err != nil {
+			return err
+		}
 	}
 	if response.Errors != nil {
-		err = json.Unmarshal(*response.Errors, errors)
+		var errs GQLErrors
+		err = json.Unmarshal(*response.Errors, &errs)
 		if err != nil {
 			return err
 		}
+		if len(errs) > 0 {
+			return errs
+		}
+	}
+	if response.Data == nil {
+		return errors.New("graphql response contained neither data nor errors")
 	}
 
 	return nil
@@ -106,6 +215,137 @@ query {
 // loginData is the response data for QUERY_GET_LOGIN
 type loginData map[string]map[string]string
 
+// refsFragment selects the branches of a repository.
+const refsFragment = `
+fragment refsFields on RefConnection {
+  totalCount
+  pageInfo {
+    endCursor
+    hasNextPage
+  }
+  nodes {
+    name
+  }
+}
+`
+
+// protectionRulesFragment selects the branch protection rules of a
+// repository.
+const protectionRulesFragment = `
+fragment protectionRulesFields on BranchProtectionRuleConnection {
+  totalCount
+  pageInfo {
+    endCursor
+    hasNextPage
+  }
+  nodes {
+    databaseId
+    pattern
+    requiresApprovingReviews
+    requiredApprovingReviewCount
+    dismissesStaleReviews
+    requiresCodeOwnerReviews
+    requiresStatusChecks
+    requiredStatusCheckContexts
+    isAdminEnforced
+    allowsForcePushes
+    allowsDeletions
+    requiresLinearHistory
+    requiresCommitSignatures
+    restrictsPushes
+    # Push allowances are fetched per rule with queryGetPushAllowances, nesting
+    # them here multiplies the node cost of the repositories query.
+    id
+  }
+}
+`
+
+// deployKeysFragment selects the deploy keys of a repository.
+const deployKeysFragment = `
+fragment deployKeysFields on DeployKeyConnection {
+  totalCount
+  pageInfo {
+    endCursor
+    hasNextPage
+  }
+  nodes {
+    id
+    title
+    readOnly
+    key
+    createdAt
+    verified
+  }
+}
+`
+
+// collaboratorsFragment selects the collaborators of a repository.
+const collaboratorsFragment = `
+fragment collaboratorsFields on RepositoryCollaboratorConnection {
+  totalCount
+  pageInfo {
+    endCursor
+    hasNextPage
+  }
+  edges {
+    permission
+    node {
+      login
+    }
+    permissionSources {
+      permission
+      source {
+        __typename
+        ... on Organization {
+          login
+        }
+        ... on Repository {
+          nameWithOwner
+        }
+        ... on Team {
+          slug
+        }
+      }
+    }
+  }
+}
+`
+
+// repoFieldsFragment is the GraphQL fragment selecting everything audit needs
+// to know about a repository.
+const repoFieldsFragment = `
+fragment repoFields on Repository {
+  owner {
+    __typename
+    login
+  }
+  name
+  nameWithOwner
+  isPrivate
+  stargazers {
+    totalCount
+  }
+  refs(first: 100, refPrefix: "refs/heads/") {
+    ...refsFields
+  }
+  mergeCommitAllowed
+  rebaseMergeAllowed
+  squashMergeAllowed
+  defaultBranchRef {
+    name
+  }
+  branchProtectionRules(first: 100) {
+    ...protectionRulesFields
+  }
+  deployKeys(first: 100) {
+    ...deployKeysFields
+  }
+  collaborators(first: 100) {
+    ...collaboratorsFields
+  }
+}
+` + refsFragment + protectionRulesFragment + deployKeysFragment + collaboratorsFragment
+
 // buildGetReposQuery takes a param (user or organization) and returns the
 // correct GraphQL query to fetch repositories under that resource
 func buildGetReposQuery(param string) string {
@@ -130,108 +370,147 @@ func buildGetReposQuery(param string) string {
                 hasPreviousPage
               }
               nodes {
-                owner {
-                  login
-                }
-                name
-                nameWithOwner
-                stargazers {
-                  totalCount
-                }
-                refs(first: 100, refPrefix: "refs/heads/") {
-                  totalCount
-                  nodes {
-                    name
-                  }
-                }
-                mergeCommitAllowed
-                rebaseMergeAllowed
-                squashMergeAllowed
-                defaultBranchRef {
-                  name
-                }
-                branchProtectionRules(first: 100) {
-                  totalCount
-                  nodes {
-                    pattern
-                  }
-                }
-                deployKeys(first: 100) {
-                  totalCount
-                  nodes {
-                    id
-                    title
-                    readOnly
-                  }
-                }
-                collaborators(first: 100) {
-                  totalCount
-                  edges {
-                    permission
-                    node {
-                      login
-                    }
-                  }
-                }
+                ...repoFields
               }
             }
           }
         }
-    `, param)
+    `, param) + repoFieldsFragment
 }
 
 // queryGetRepo is the GraphQL query to get details about a repository
 const queryGetRepo = `
 query getRepo($owner: String!, $name: String!) {
   repository(owner: $owner, name: $name) {
-    owner {
-      login
-    }
-    name
-    nameWithOwner
-    stargazers {
-      totalCount
-    }
-    mergeCommitAllowed
-    rebaseMergeAllowed
-    squashMergeAllowed
-    defaultBranchRef {
-      name
-    }
-    refs(first: 100, refPrefix: "refs/heads/") {
-      totalCount
-      nodes {
-        name
+    ...repoFields
+  }
+}
+` + repoFieldsFragment
+
+// buildGetRepoConnectionQuery returns the GraphQL query to fetch the next
+// page of a single connection on a repository.
+func buildGetRepoConnectionQuery(connection string) string {
+	return fmt.Sprintf(`
+query getRepoConnection($owner: String!, $name: String!, $cursor: String!) {
+  repository(owner: $owner, name: $name) {
+    %s
+  }
+}
+`, connection)
+}
+
+var (
+	// queryGetRepoRefs is the GraphQL query to get the next page of branches.
+	queryGetRepoRefs = buildGetRepoConnectionQuery(`refs(first: 100, refPrefix: "refs/heads/", after: $cursor) {
+      ...refsFields
+    }`) + refsFragment
+	// queryGetRepoProtectionRules is the GraphQL query to get the next page of
+	// branch protection rules.
+	queryGetRepoProtectionRules = buildGetRepoConnectionQuery(`branchProtectionRules(first: 100, after: $cursor) {
+      ...protectionRulesFields
+    }`) + protectionRulesFragment
+	// queryGetRepoDeployKeys is the GraphQL query to get the next page of
+	// deploy keys.
+	queryGetRepoDeployKeys = buildGetRepoConnectionQuery(`deployKeys(first: 100, after: $cursor) {
+      ...deployKeysFields
+    }`) + deployKeysFragment
+	// queryGetRepoCollaborators is the GraphQL query to get the next page of
+	// collaborators.
+	queryGetRepoCollaborators = buildGetRepoConnectionQuery(`collaborators(first: 100, after: $cursor) {
+      ...collaboratorsFields
+    }`) + collaboratorsFragment
+)
+
+// queryGetPushAllowances is the GraphQL query to get a page of the push
+// allowances of a branch protection rule.
+const queryGetPushAllowances = `
+query getPushAllowances($id: ID!, $cursor: String) {
+  node(id: $id) {
+    ... on BranchProtectionRule {
+      pushAllowances(first: 100, after: $cursor) {
+        pageInfo {
+          endCursor
+          hasNextPage
+        }
+        nodes {
+          actor {
+            ... on User {
+              login
+            }
+            ... on Team {
+              slug
+            }
+            ... on App {
+              slug
+            }
+          }
+        }
       }
     }
-    branchProtectionRules(first: 100) {
-      totalCount
+  }
+}
+`
+
+// queryGetOrgDomains is the GraphQL query to get the domains of an
+// organization.
+const queryGetOrgDomains = `
+query getOrgDomains($login: String!) {
+  organization(login: $login) {
+    domains(first: 100) {
       nodes {
-        pattern
+        domain
+        isVerified
       }
     }
-    deployKeys(first: 100) {
-      totalCount
-      nodes {
-        id
-        title
-        readOnly
-      }
+  }
+}
+`
+
+// queryGetOrgOAuthAuditLog is the GraphQL query to get the audit log entries
+// about the OAuth App access restrictions of an organization.
+const queryGetOrgOAuthAuditLog = `
+query getOrgOAuthAuditLog($login: String!) {
+  organization(login: $login) {
+    enabled: auditLog(first: 1, query: "action:org.enable_oauth_app_restrictions", orderBy: {field: CREATED_AT, direction: DESC}) {
+      ...oauthAuditEntries
     }
-    collaborators(first: 100) {
-      totalCount
-      edges {
-        permission
-        node {
-          login
-        }
-      }
+    disabled: auditLog(first: 1, query: "action:org.disable_oauth_app_restrictions", orderBy: {field: CREATED_AT, direction: DESC}) {
+      ...oauthAuditEntries
+    }
+    approved: auditLog(first: 100, query: "action:org.oauth_app_access_approved", orderBy: {field: CREATED_AT, direction: DESC}) {
+      ...oauthAuditEntries
+    }
+    denied: auditLog(first: 100, query: "action:org.oauth_app_access_denied", orderBy: {field: CREATED_AT, direction: DESC}) {
+      ...oauthAuditEntries
     }
   }
 }
 
+fragment oauthAuditEntries on OrganizationAuditEntryConnection {
+  nodes {
+    ... on AuditEntry {
+      createdAt
+    }
+    ... on OauthApplicationAuditEntryData {
+      oauthApplicationName
+    }
+  }
+}
 `
 
+type orgDomainsResponse struct {
+	Org struct {
+		Domains struct {
+			Nodes []orgDomain `json:"nodes"`
+		} `json:"domains"`
+	} `json:"organization"`
+}
+
+type orgDomain struct {
+	Domain     string `json:"domain"`
+	IsVerified bool   `json:"isVerified"`
+}
+
 type userReposResponse struct {
 	User repos `json:"user"`
 }
@@ -261,40 +540,124 @@ type repoResponse struct {
 }
 
 type ghrepo struct {
-	Name                  string           `json:"name"`
-	Owner                 collaboratorNode `json:"owner"`
-	NameWithOwner         string           `json:"nameWithOwner"`
-	Stargazers            countNodeName    `json:"stargazers"`
-	MergeCommitAllowed    bool             `json:"mergeCommitAllowed"`
-	RebaseMergeAllowed    bool             `json:"rebaseMergeAllowed"`
-	SquashMergeAllowed    bool             `json:"squashMergeAllowed"`
-	Refs                  countNodeName    `json:"refs"`
-	BranchProtectionRules countNodeName    `json:"branchProtectionRules"`
-	DeployKeys            countNodeName    `json:"deployKeys"`
-	Collaborators         collaborators    `json:"collaborators"`
+	Name                  string          `json:"name"`
+	Owner                 repoOwner       `json:"owner"`
+	NameWithOwner         string          `json:"nameWithOwner"`
+	IsPrivate             bool            `json:"isPrivate"`
+	Stargazers            countNodeName   `json:"stargazers"`
+	MergeCommitAllowed    bool            `json:"mergeCommitAllowed"`
+	RebaseMergeAllowed    bool            `json:"rebaseMergeAllowed"`
+	SquashMergeAllowed    bool            `json:"squashMergeAllowed"`
+	DefaultBranchRef      nodeElement     `json:"defaultBranchRef"`
+	Refs                  countNodeName   `json:"refs"`
+	BranchProtectionRules protectionRules `json:"branchProtectionRules"`
+	DeployKeys            countNodeName   `json:"deployKeys"`
+	Collaborators         collaborators   `json:"collaborators"`
 }
 
 type countNodeName struct {
 	TotalCount int           `json:"totalCount"`
+	PageInfo   pageInfo      `json:"pageInfo"`
 	Nodes      []nodeElement `json:"nodes"`
 }
 
 type nodeElement struct {
-	Name     string `json:"name"`
-	Title    string `json:"title"`
-	ReadOnly bool   `json:"readOnly"`
-	ID       string `json:"id"`
-	Pattern  string `json:"pattern"`
+	Name      string    `json:"name"`
+	Title     string    `json:"title"`
+	ReadOnly  bool      `json:"readOnly"`
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"createdAt"`
+	Verified  bool      `json:"verified"`
+}
+
+type protectionRules struct {
+	TotalCount int                    `json:"totalCount"`
+	PageInfo   pageInfo               `json:"pageInfo"`
+	Nodes      []branchProtectionRule `json:"nodes"`
+}
+
+type branchProtectionRule struct {
+	ID                           string         `json:"id"`
+	DatabaseID                   int64          `json:"databaseId"`
+	Pattern                      string         `json:"pattern"`
+	RequiresApprovingReviews     bool           `json:"requiresApprovingReviews"`
+	RequiredApprovingReviewCount int            `json:"requiredApprovingReviewCount"`
+	DismissesStaleReviews        bool           `json:"dismissesStaleReviews"`
+	RequiresCodeOwnerReviews     bool           `json:"requiresCodeOwnerReviews"`
+	RequiresStatusChecks         bool           `json:"requiresStatusChecks"`
+	RequiredStatusCheckContexts  []string       `json:"requiredStatusCheckContexts"`
+	IsAdminEnforced              bool           `json:"isAdminEnforced"`
+	AllowsForcePushes            bool           `json:"allowsForcePushes"`
+	AllowsDeletions              bool           `json:"allowsDeletions"`
+	RequiresLinearHistory        bool           `json:"requiresLinearHistory"`
+	RequiresCommitSignatures     bool           `json:"requiresCommitSignatures"`
+	RestrictsPushes              bool           `json:"restrictsPushes"`
+	PushAllowances               pushAllowances `json:"pushAllowances"`
+}
+
+type pushAllowances struct {
+	PageInfo pageInfo        `json:"pageInfo"`
+	Nodes    []pushAllowance `json:"nodes"`
+}
+
+// pushAllowancesResponse is the response of queryGetPushAllowances.
+type pushAllowancesResponse struct {
+	Node struct {
+		PushAllowances pushAllowances `json:"pushAllowances"`
+	} `json:"node"`
+}
+
+type pushAllowance struct {
+	Actor pushActor `json:"actor"`
+}
+
+// pushActor is the union of the User, Team and App actors that can be allowed
+// to push to a protected branch.
+type pushActor struct {
+	Login string `json:"login"`
+	Slug  string `json:"slug"`
+}
+
+// name returns the login of a user or the slug of a team or app.
+func (a pushActor) name() string {
+	if a.Login != "" {
+		return a.Login
+	}
+	return a.Slug
 }
 
 type collaborators struct {
 	TotalCount int                `json:"totalCount"`
+	PageInfo   pageInfo           `json:"pageInfo"`
 	Edges      []collaboratorEdge `json:"edges"`
 }
 
 type collaboratorEdge struct {
-	Permission string           `json:"permission"`
-	Node       collaboratorNode `json:"node"`
+	Permission        string                   `json:"permission"`
+	Node              collaboratorNode         `json:"node"`
+	PermissionSources []collaboratorPermission `json:"permissionSources"`
+}
+
+type collaboratorPermission struct {
+	Permission string            `json:"permission"`
+	Source     permissionGranter `json:"source"`
+}
+
+// permissionGranter is the union of the Organization, Repository and Team
+// a permission can be granted by.
+type permissionGranter struct {
+	Typename      string `json:"__typename"`
+	Login         string `json:"login"`
+	NameWithOwner string `json:"nameWithOwner"`
+	Slug          string `json:"slug"`
+}
+
+// repoOwner is the user or organization owning a repository.
+type repoOwner struct {
+	// Typename is either User or Organization.
+	Typename string `json:"__typename"`
+	Login    string `json:"login"`
 }
 
 type collaboratorNode struct {
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGQLErrorsByRepository(t *testing.T) {
+	// Decode the errors the way Execute does so the indexes are float64.
+	var errs GQLErrors
+	if err := json.Unmarshal([]byte(`[
+		{"message": "Resource protected by organization SAML enforcement.", "path": ["organization", "repositories", "nodes", 2, "collaborators"]},
+		{"message": "Something went wrong.", "path": ["organization", "repositories", "nodes", 2, "branchProtectionRules", "nodes", 0, "pushAllowances"]},
+		{"message": "Could not resolve to a node.", "path": ["organization", "repositories", "nodes", 5]},
+		{"message": "Must have admin rights to Repository.", "path": ["repository", "deployKeys"]},
+		{"message": "Organization members are not visible.", "path": ["organization", "membersWithRole"]},
+		{"message": "Timeout on repositories.", "path": ["organization", "repositories", "nodes"]},
+		{"message": "Rate limited."}
+	]`), &errs); err != nil {
+		t.Fatal(err)
+	}
+
+	attributed, unattributed := errs.byRepository()
+
+	want := map[int][]string{
+		0: {"deployKeys: Must have admin rights to Repository."},
+		2: {
+			"collaborators: Resource protected by organization SAML enforcement.",
+			"branchProtectionRules.nodes.pushAllowances: Something went wrong.",
+		},
+		5: {"Could not resolve to a node."},
+	}
+	if !reflect.DeepEqual(attributed, want) {
+		t.Errorf("attributed = %v, want %v", attributed, want)
+	}
+
+	var messages []string
+	for _, err := range unattributed {
+		messages = append(messages, err.Message)
+	}
+	wantMessages := []string{"Organization members are not visible.", "Timeout on repositories.", "Rate limited."}
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Errorf("unattributed = %v, want %v", messages, wantMessages)
+	}
+}
+
+func TestRepositoryPath(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		path  []interface{}
+		index int
+		field string
+		ok    bool
+	}{
+		{"repository", []interface{}{"repository", "refs"}, 0, "refs", true},
+		{"repositories node", []interface{}{"user", "repositories", "nodes", float64(7), "deployKeys", "nodes", float64(1), "key"}, 7, "deployKeys.nodes.key", true},
+		{"null node", []interface{}{"organization", "repositories", "nodes", float64(3)}, 3, "", true},
+		{"organization", []interface{}{"organization", "domains"}, 0, "", false},
+		{"without index", []interface{}{"organization", "repositories", "nodes"}, 0, "", false},
+		{"connection", []interface{}{"organization", "repositories"}, 0, "", false},
+		{"empty", nil, 0, "", false},
+	} {
+		index, field, ok := repositoryPath(tc.path)
+		if index != tc.index || field != tc.field || ok != tc.ok {
+			t.Errorf("%s: repositoryPath(%v) = (%d, %q, %t), want (%d, %q, %t)",
+				tc.name, tc.path, index, field, ok, tc.index, tc.field, tc.ok)
+		}
+	}
+}
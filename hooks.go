@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+// credentialParams are the query parameters of a hook URL that usually hold
+// credentials.
+var credentialParams = []string{"token", "access_token", "key", "api_key", "apikey", "secret", "password", "auth"}
+
+// newHookReport builds the report of a hook, inspecting where it delivers its
+// payloads. allowedHosts are the approved destination domains; when empty,
+// destinations are not checked.
+func newHookReport(h *github.Hook, allowedHosts []string) hookReport {
+	report := hookReport{
+		ID:     h.GetID(),
+		Name:   h.GetName(),
+		Active: h.GetActive(),
+		URL:    h.GetURL(),
+		Events: []string{},
+	}
+	if h.Events != nil {
+		report.Events = h.Events
+	}
+
+	// Secrets are masked by the API, but whether one is set shows.
+	if secret, ok := h.Config["secret"].(string); ok && secret != "" {
+		report.HasSecret = true
+	}
+	report.InsecureSSL = configFlag(h.Config["insecure_ssl"])
+
+	target, _ := h.Config["url"].(string)
+	if target == "" {
+		// Legacy services do not deliver to a URL of their own.
+		return report
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		report.Target = "<invalid URL>"
+		return report
+	}
+	if u.User != nil {
+		report.CredentialsInURL = true
+		u.User = url.User("REDACTED")
+	}
+	q := u.Query()
+	for k := range q {
+		if contains(credentialParams, strings.ToLower(k)) {
+			report.CredentialsInURL = true
+			q.Set(k, "REDACTED")
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	report.Target = u.String()
+	report.Host = strings.ToLower(u.Hostname())
+	report.InsecureURL = u.Scheme == "http"
+	if len(allowedHosts) > 0 {
+		approved := hostAllowed(report.Host, allowedHosts)
+		report.ApprovedHost = &approved
+	}
+
+	return report
+}
+
+// configFlag converts a boolean setting of a hook config, which GitHub returns
+// either as "0"/"1" or as a number, into a bool.
+func configFlag(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return v == "1"
+	case float64:
+		return v == 1
+	case bool:
+		return v
+	}
+	return false
+}
+
+// hostAllowed reports whether the host is one of the domains, or a subdomain
+// of one of them.
+func hostAllowed(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// receivesPushes reports whether the hook is sent push events.
+func (h hookReport) receivesPushes() bool {
+	return contains(h.Events, "push") || contains(h.Events, "*")
+}
+
+// hookFindings runs the hook rules against the hooks of a repository, or of an
+// organization. private tells whether the hooks can be sent private code.
+func hookFindings(owner string, private bool, hooks []hookReport) []finding {
+	findings := []finding{}
+
+	for _, h := range hooks {
+		id := fmt.Sprintf("%d", h.ID)
+		if !h.Active {
+			findings = append(findings, newFinding(ruleHookInactive, owner, id,
+				fmt.Sprintf("hook %s (%s) is inactive", h.Name, h.URL)))
+		}
+		if h.InsecureURL {
+			findings = append(findings, newFinding(ruleHookInsecureURL, owner, id,
+				fmt.Sprintf("hook %s delivers to %s over plain http", h.Name, h.Target)))
+		}
+		if h.InsecureSSL {
+			findings = append(findings, newFinding(ruleHookInsecureSSL, owner, id,
+				fmt.Sprintf("hook %s delivers to %s without verifying its certificate", h.Name, h.Target)))
+		}
+		if h.Target != "" && !h.HasSecret {
+			findings = append(findings, newFinding(ruleHookNoSecret, owner, id,
+				fmt.Sprintf("hook %s delivers to %s without a secret to sign payloads", h.Name, h.Target)))
+		}
+		if h.CredentialsInURL {
+			findings = append(findings, newFinding(ruleHookCredentialsInURL, owner, id,
+				fmt.Sprintf("hook %s has credentials embedded in its URL %s", h.Name, h.Target)))
+		}
+		switch h.Health {
+		case hookFailing:
+			msg := fmt.Sprintf("hook %s is failing, its last delivery got status %d", h.Name, h.LastStatusCode)
+			if h.LastStatusCode == 0 {
+				msg = fmt.Sprintf("hook %s is failing, its last delivery could not connect", h.Name)
+			}
+			if h.FailingSince != nil {
+				msg += fmt.Sprintf(", failing for %s", formatAge(time.Since(*h.FailingSince)))
+			}
+			findings = append(findings, newFinding(ruleHookFailing, owner, id, msg))
+		case hookNeverDelivered:
+			findings = append(findings, newFinding(ruleHookNeverDelivered, owner, id,
+				fmt.Sprintf("hook %s has never delivered a payload", h.Name)))
+		}
+		if contains(h.Events, "*") {
+			findings = append(findings, newFinding(ruleHookWildcardEvents, owner, id,
+				fmt.Sprintf("hook %s is sent every event", h.Name)))
+		}
+		if h.ApprovedHost != nil && !*h.ApprovedHost {
+			if private && h.receivesPushes() {
+				findings = append(findings, newFinding(ruleHookUnapprovedPrivatePush, owner, id,
+					fmt.Sprintf("hook %s sends push events of private code to unapproved host %s", h.Name, h.Host)))
+			} else {
+				findings = append(findings, newFinding(ruleHookUnapprovedHost, owner, id,
+					fmt.Sprintf("hook %s delivers to unapproved host %s", h.Name, h.Host)))
+			}
+		}
+	}
+
+	return findings
+}
+
+const (
+	hookHealthy        = "healthy"
+	hookFailing        = "failing"
+	hookNeverDelivered = "never-delivered"
+	// hookUnknown is the health of a hook whose deliveries could not be
+	// listed.
+	hookUnknown = "unknown"
+
+	// hookDeliveriesPerPage is how many of the most recent deliveries of a
+	// hook are looked at to tell since when it has been failing.
+	hookDeliveriesPerPage = 50
+)
+
+// repoHook is a hook along with the response to its last delivery, which
+// github.Hook leaves out.
+type repoHook struct {
+	github.Hook
+	LastResponse hookResponse `json:"last_response"`
+}
+
+// hookResponse is the response to the last delivery of a hook.
+type hookResponse struct {
+	// Code is the HTTP status code, nil when the hook was never delivered.
+	Code   *int   `json:"code"`
+	Status string `json:"status"`
+}
+
+// hookDelivery is a delivery of a hook.
+type hookDelivery struct {
+	ID          int64     `json:"id"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	StatusCode  int       `json:"status_code"`
+	Event       string    `json:"event"`
+}
+
+// repoHooksPath returns the REST API path of the hooks of the repository.
+func repoHooksPath(repo ghrepo) string {
+	return fmt.Sprintf("repos/%s/%s/hooks", repo.Owner.Login, repo.Name)
+}
+
+// orgHooksPath returns the REST API path of the hooks of the organization.
+func orgHooksPath(org string) string {
+	return fmt.Sprintf("orgs/%s/hooks", org)
+}
+
+// listHooks lists the hooks at path, the hooks of a repository or of an
+// organization.
+func (a *auditor) listHooks(ctx context.Context, path string) ([]*repoHook, error) {
+	var hooks []*repoHook
+	if _, err := a.restGet(ctx, path+"?per_page=100", &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// listHookDeliveries lists the most recent deliveries of the hook with the id
+// at path, newest first.
+func (a *auditor) listHookDeliveries(ctx context.Context, path string, id int64) ([]hookDelivery, error) {
+	var deliveries []hookDelivery
+	if _, err := a.restGet(ctx, fmt.Sprintf("%s/%d/deliveries?per_page=%d", path, id, hookDeliveriesPerPage), &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// auditHooks builds the reports of the hooks at path, checking the health of
+// each. The health of hooks whose deliveries could not be listed is unknown,
+// which is only an error when it is not down to the token lacking access.
+func (a *auditor) auditHooks(ctx context.Context, path string, hooks []*repoHook) ([]hookReport, []string, error) {
+	reports := []hookReport{}
+	errs := []string{}
+	for _, h := range hooks {
+		hook := newHookReport(&h.Hook, a.hookHosts)
+		logrus.Debugf("Executing REST query to list deliveries of hook %d at %s", h.GetID(), path)
+		deliveries, err := a.listHookDeliveries(ctx, path, h.GetID())
+		if err != nil {
+			if isRateLimit(err) {
+				return nil, nil, err
+			}
+			hook.Health = hookUnknown
+			if !isNotVisible(err) {
+				errs = append(errs, fmt.Sprintf("listing deliveries of hook %d failed: %v", h.GetID(), err))
+			}
+		} else {
+			setHookHealth(&hook, h, deliveries)
+		}
+		reports = append(reports, hook)
+	}
+	return reports, errs, nil
+}
+
+// setHookHealth classifies the hook from its recent deliveries, falling back
+// to the response to its last delivery when there are none.
+func setHookHealth(report *hookReport, h *repoHook, deliveries []hookDelivery) {
+	if len(deliveries) < 1 {
+		if h.LastResponse.Code == nil {
+			report.Health = hookNeverDelivered
+			return
+		}
+		report.LastStatusCode = *h.LastResponse.Code
+		if successful(report.LastStatusCode) {
+			report.Health = hookHealthy
+		} else {
+			report.Health = hookFailing
+		}
+		return
+	}
+
+	last := deliveries[0]
+	report.LastStatusCode = last.StatusCode
+	report.LastDelivery = &last.DeliveredAt
+	if successful(last.StatusCode) {
+		report.Health = hookHealthy
+		return
+	}
+
+	// The hook has been failing since the oldest of the failed deliveries
+	// that followed the last successful one.
+	report.Health = hookFailing
+	since := last.DeliveredAt
+	for _, d := range deliveries {
+		if successful(d.StatusCode) {
+			break
+		}
+		since = d.DeliveredAt
+	}
+	report.FailingSince = &since
+}
+
+// successful reports whether the status code of a delivery is a success. A
+// delivery that could not connect has no status code.
+func successful(code int) bool {
+	return code >= 200 && code < 300
+}
+
+// formatAge returns a human readable duration, in days once it is over two
+// days.
+func formatAge(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	}
+	return d.Round(time.Minute).String()
+}
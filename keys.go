@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const keysHelp = `List every deploy key, grouped by public key.`
+
+type keysCommand struct{}
+
+func (cmd *keysCommand) Name() string      { return "keys" }
+func (cmd *keysCommand) Args() string      { return "[OPTIONS]" }
+func (cmd *keysCommand) ShortHelp() string { return keysHelp }
+func (cmd *keysCommand) LongHelp() string {
+	return keysHelp + `
+
+Every deploy key of the audited repositories is listed under its SHA256
+fingerprint, with the repositories it has access to, whether it can write to
+them, its title and its age. Use -format json to get the inventory as JSON.`
+}
+func (cmd *keysCommand) Hidden() bool { return false }
+
+func (cmd *keysCommand) Register(fs *flag.FlagSet) {}
+
+func (cmd *keysCommand) Run(ctx context.Context, args []string) error {
+	if err := checkAuditFlags(); err != nil {
+		return err
+	}
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("unknown keys format %q (must be one of: %s, %s)", format, formatText, formatJSON)
+	}
+
+	ctx = handleSignals(ctx)
+
+	inventory := &keyInventory{w: os.Stdout, json: format == formatJSON, keys: map[string]*inventoryKey{}}
+	summary := &summaryReporter{reporter: inventory, w: os.Stderr}
+
+	a, login, err := newAuditor(ctx, summary)
+	if err != nil {
+		return err
+	}
+	a.handle = a.handleKeys
+
+	a.auditAll(ctx, login, summary)
+	if err := summary.Close(); err != nil {
+		return err
+	}
+
+	if code := summary.exitCode(severity(failOn)); code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
+// newDeployKeyReport builds the report of a deploy key of the repository. The
+// report is filled as much as possible when the public key cannot be parsed.
+func newDeployKeyReport(repo ghrepo, k nodeElement) (deployKeyReport, error) {
+	key := deployKeyReport{
+		ID:        k.ID,
+		Title:     k.Title,
+		ReadOnly:  k.ReadOnly,
+		CreatedAt: k.CreatedAt,
+		AgeDays:   int(time.Since(k.CreatedAt).Hours() / 24),
+		Verified:  k.Verified,
+	}
+	if keyURL, err := buildDeployKeyURL(repo.Owner.Login, repo.Name, k.ID); err == nil {
+		key.URL = keyURL
+	}
+
+	pub, err := parseSSHKey(k.Key)
+	if err != nil {
+		return key, fmt.Errorf("parsing deploy key %q failed: %v", k.Title, err)
+	}
+	key.Type = pub.Type
+	key.Bits = pub.Bits
+	key.Fingerprint = pub.Fingerprint
+	key.Weak = pub.weak()
+	return key, nil
+}
+
+// handleKeys only audits the deploy keys of the repository, skipping the
+// repositories without any.
+func (a *auditor) handleKeys(ctx context.Context, repo ghrepo) (*repoReport, error) {
+	if len(repo.DeployKeys.Nodes) < 1 {
+		return nil, nil
+	}
+
+	report := newRepoReport(repo)
+	for _, k := range repo.DeployKeys.Nodes {
+		key, err := newDeployKeyReport(repo, k)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		report.DeployKeys = append(report.DeployKeys, key)
+	}
+	report.Findings = repoFindings(report)
+	return report, nil
+}
+
+// inventoryKey is a public key and every repository it is a deploy key of.
+type inventoryKey struct {
+	Fingerprint  string          `json:"fingerprint"`
+	Type         string          `json:"type,omitempty"`
+	Bits         int             `json:"bits,omitempty"`
+	Weak         bool            `json:"weak"`
+	Repositories []inventoryRepo `json:"repositories"`
+}
+
+// inventoryRepo is a repository a key has access to.
+type inventoryRepo struct {
+	Repository string `json:"repository"`
+	deployKeyReport
+}
+
+// keyInventory is a reporter grouping the deploy keys of every repository by
+// fingerprint, written on Close.
+type keyInventory struct {
+	w    io.Writer
+	json bool
+
+	keys map[string]*inventoryKey
+	// order holds the fingerprints in the order they were first seen.
+	order []string
+}
+
+func (k *keyInventory) Report(r *repoReport) error {
+	for _, key := range r.DeployKeys {
+		// Keys that could not be parsed are listed on their own.
+		fingerprint := key.Fingerprint
+		if fingerprint == "" {
+			fingerprint = "unknown key " + key.ID
+		}
+
+		ik, ok := k.keys[fingerprint]
+		if !ok {
+			ik = &inventoryKey{
+				Fingerprint:  fingerprint,
+				Type:         key.Type,
+				Bits:         key.Bits,
+				Weak:         key.Weak,
+				Repositories: []inventoryRepo{},
+			}
+			k.keys[fingerprint] = ik
+			k.order = append(k.order, fingerprint)
+		}
+		ik.Repositories = append(ik.Repositories, inventoryRepo{Repository: r.Repository, deployKeyReport: key})
+	}
+	return nil
+}
+
+func (k *keyInventory) Close() error {
+	keys := []*inventoryKey{}
+	for _, f := range k.order {
+		keys = append(keys, k.keys[f])
+	}
+
+	if k.json {
+		enc := json.NewEncoder(k.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Keys []*inventoryKey `json:"keys"`
+		}{keys})
+	}
+
+	for _, key := range keys {
+		output := fmt.Sprintf("%s -> \n", key.Fingerprint)
+		if key.Type != "" {
+			output += fmt.Sprintf("\tType: %s %d bits", key.Type, key.Bits)
+			if key.Weak {
+				output += " [weak]"
+			}
+			output += "\n"
+		}
+		rstr := []string{}
+		for _, r := range key.Repositories {
+			line := fmt.Sprintf("\t\t%s - %s - ro:%t age:%dd verified:%t", r.Repository, r.Title, r.ReadOnly, r.AgeDays, r.Verified)
+			if r.URL != "" {
+				line += fmt.Sprintf(" (%s)", r.URL)
+			}
+			rstr = append(rstr, line)
+		}
+		output += fmt.Sprintf("\tRepositories (%d):\n%s\n", len(rstr), strings.Join(rstr, "\n"))
+		if _, err := fmt.Fprintf(k.w, "%s--\n\n", output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"golang.org/x/oauth2"
 
@@ -21,10 +24,18 @@ import (
 )
 
 var (
-	token string
-	orgs  stringSlice
-	repo  string
-	owner bool
+	token        string
+	orgs         stringSlice
+	repo         string
+	owner        bool
+	format       string
+	concurrency  int
+	policyFile   string
+	failOn       string
+	snapshotOut  string
+	suppressFile string
+	hookHosts    stringSlice
+	prodEnvs     stringSlice
 
 	debug bool
 )
@@ -41,6 +52,27 @@ func (s *stringSlice) Set(value string) error {
 	return nil
 }
 
+// checkAuditFlags validates the flags needed to audit repositories.
+func checkAuditFlags() error {
+	if token == "" {
+		return errors.New("GitHub token cannot be empty")
+	}
+
+	if owner && len(orgs) > 0 {
+		return errors.New("cannot filter by organization while restricting to repos the token owner owns")
+	}
+
+	if concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+
+	if failOn != "" && severity(failOn).rank() < 1 {
+		return fmt.Errorf("invalid -fail-on severity %q (must be one of: low, medium, high, critical)", failOn)
+	}
+
+	return nil
+}
+
 func main() {
 	// Create a new cli program.
 	p := cli.NewProgram()
@@ -54,12 +86,28 @@ func main() {
 	// Setup the global flags.
 	p.FlagSet = flag.NewFlagSet("global", flag.ExitOnError)
 	p.FlagSet.StringVar(&token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub API token (or env var GITHUB_TOKEN)")
-	p.FlagSet.Var(&orgs, "orgs", "specific orgs to check (e.g. 'genuinetools')")
+	p.FlagSet.Var(&orgs, "orgs", "specific orgs to check, along with their settings and apps (e.g. 'genuinetools'); which repos an app was granted is not listable with a user token")
 	p.FlagSet.StringVar(&repo, "repo", "", "specific repo to test (e.g. 'genuinetools/audit')")
 	p.FlagSet.BoolVar(&owner, "owner", false, "only audit repos the token owner owns")
+	p.FlagSet.StringVar(&format, "format", formatText, "output format (text, json, ndjson, sarif)")
+	p.FlagSet.IntVar(&concurrency, "concurrency", 4, "number of repositories to audit in parallel")
+	p.FlagSet.StringVar(&policyFile, "policy", "", "YAML policy file to evaluate every repository against")
+	p.FlagSet.StringVar(&failOn, "fail-on", "", "exit with code 2 if there are findings at or above this severity (low, medium, high, critical)")
+	p.FlagSet.StringVar(&snapshotOut, "snapshot", "", "save the run to this file to compare it with the diff command later")
+	p.FlagSet.StringVar(&suppressFile, "suppressions", "", "YAML file of accepted findings to leave out of the report")
+	p.FlagSet.Var(&hookHosts, "hook-host", "approved domain webhooks may deliver to, can be repeated (e.g. 'travis-ci.org')")
+	p.FlagSet.Var(&prodEnvs, "production-env", "word marking an environment as production instead of prod, production and live, can be repeated")
 	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
 	p.FlagSet.BoolVar(&debug, "debug", false, "enable debug logging")
 
+	// Setup the commands.
+	p.Commands = []cli.Command{
+		&diffCommand{},
+		&keysCommand{},
+		&offboardCommand{},
+		&whoCommand{},
+	}
+
 	// Set the before function.
 	p.Before = func(ctx context.Context) error {
 		// Set the log level.
@@ -67,84 +115,73 @@ func main() {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
 
-		if token == "" {
-			return errors.New("GitHub token cannot be empty")
-		}
-
-		if owner && len(orgs) > 0 {
-			return errors.New("cannot filter by organization while restricting to repos the token owner owns")
-		}
-
 		return nil
 	}
 
 	// Set the main program action.
 	p.Action = func(ctx context.Context, args []string) error {
-		// On ^C, or SIGTERM handle exit.
-		signals := make(chan os.Signal)
-		signal.Notify(signals, os.Interrupt)
-		signal.Notify(signals, syscall.SIGTERM)
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithCancel(ctx)
-		go func() {
-			for sig := range signals {
-				cancel()
-				logrus.Infof("Received %s, exiting.", sig.String())
-				os.Exit(0)
-			}
-		}()
+		if err := checkAuditFlags(); err != nil {
+			return err
+		}
 
-		// Create the http client.
-		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: token},
-		)
-		tc := oauth2.NewClient(ctx, ts)
-
-		// Create the github rest client.
-		restClient := github.NewClient(tc)
-
-		// Create the github graphql client.
-		// Create a graphql client
-		graphqlClient := NewGQLClient("https://api.github.com/graphql", map[string]string{
-			"Authorization": "bearer " + token,
-		})
-
-		logrus.Debug("Getting current user...")
-		// Get the current user
-		var respData loginData
-		if err := graphqlClient.Execute(GQLRequest{
-			Query: queryGetLogin,
-		}, &respData, nil); err != nil {
-			return fmt.Errorf("getting user failed: %v", err)
-		}
-		username := respData["viewer"]["login"]
-		logrus.Debugf("current user is %s", username)
-
-		var affiliations []string
-		if owner {
-			affiliations = []string{"OWNER"}
-		} else {
-			affiliations = []string{"OWNER", "COLLABORATOR", "ORGANIZATION_MEMBER"}
-		}
-		logrus.Debugf("Setting affiliations to %s", strings.Join(affiliations, ","))
-
-		if len(orgs) > 0 {
-			// get repos for each org
-			for _, org := range orgs {
-				logrus.Debugf("Getting repositories for org %s...", org)
-				err := getRepositories(ctx, restClient, graphqlClient, affiliations, repo, org, "", true)
-				if err != nil {
-					return err
-				}
+		// Create the reporter for the output format.
+		rep, err := newReporter(format, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if snapshotOut != "" {
+			rep = newSnapshotReporter(rep, snapshotOut)
+		}
+
+		// Load the policy, if any.
+		var pol *policy
+		if policyFile != "" {
+			pol, err = loadPolicy(policyFile)
+			if err != nil {
+				return err
 			}
-		} else {
-			// get repos for the user only
-			logrus.Debugf("Getting repositories for user %s...", username)
-			err := getRepositories(ctx, restClient, graphqlClient, affiliations, repo, username, "", false)
+			registerRules(pol.rules())
+		}
+
+		// Load the suppressions, if any.
+		var sup *suppressions
+		if suppressFile != "" {
+			sup, err = loadSuppressions(suppressFile)
 			if err != nil {
 				return err
 			}
+		}
+
+		ctx = handleSignals(ctx)
+
+		// Keep track of what could not be audited.
+		summary := &summaryReporter{reporter: rep, w: os.Stderr}
+
+		a, login, err := newAuditor(ctx, summary)
+		if err != nil {
+			return err
+		}
+		a.policy = pol
+		a.suppressions = sup
+		a.hookHosts = hookHosts
+		a.productionEnvs = prodEnvs
+		if len(prodEnvs) < 1 {
+			a.productionEnvs = defaultProductionEnvs
+		}
+
+		// Audit the settings of the organizations, unless only a single
+		// repository is audited.
+		if repo == "" {
+			a.auditOrgs(ctx, summary)
+		}
 
+		a.auditAll(ctx, login, summary)
+		if err := summary.Close(); err != nil {
+			return err
+		}
+
+		if code := summary.exitCode(severity(failOn)); code != 0 {
+			os.Exit(code)
 		}
 		return nil
 	}
@@ -153,11 +190,127 @@ func main() {
 	p.Run()
 }
 
-func getRepositories(ctx context.Context, restClient *github.Client, graphqlClient *GQLClient, affiliations []string, searchRepo string, login string, cursor string, isOrg bool) error {
+// handleSignals returns a context that is canceled on ^C or SIGTERM, before
+// exiting.
+func handleSignals(ctx context.Context) context.Context {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	signal.Notify(signals, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		for sig := range signals {
+			cancel()
+			logrus.Infof("Received %s, exiting.", sig.String())
+			os.Exit(0)
+		}
+	}()
+	return ctx
+}
+
+// newAuditor creates the GitHub clients for the token and returns an auditor
+// sending its reports to rep, along with the login of the token owner.
+func newAuditor(ctx context.Context, rep reporter) (*auditor, string, error) {
+	// Create the transport shared by both clients so they respect the
+	// same rate limits.
+	transport := newRateLimitTransport(http.DefaultTransport)
+
+	// Create the http client.
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	)
+	tc := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport}), ts)
+
+	// Create the github rest client.
+	restClient := github.NewClient(tc)
+
+	// Create the github graphql client.
+	// Create a graphql client
+	graphqlClient := NewGQLClient("https://api.github.com/graphql", &http.Client{Transport: transport}, map[string]string{
+		"Authorization": "bearer " + token,
+	})
+
+	logrus.Debug("Getting current user...")
+	// Get the current user
+	var respData loginData
+	if err := graphqlClient.Execute(GQLRequest{
+		Query: queryGetLogin,
+	}, &respData); err != nil {
+		return nil, "", fmt.Errorf("getting user failed: %v", err)
+	}
+	username := respData["viewer"]["login"]
+	logrus.Debugf("current user is %s", username)
+
+	return &auditor{
+		restClient:    restClient,
+		graphqlClient: graphqlClient,
+		reporter:      rep,
+		teams:         newTeamCache(restClient),
+		concurrency:   concurrency,
+	}, username, nil
+}
+
+// auditAll audits the repositories of every organization passed with -orgs,
+// or those of the token owner, recording the ones that could not be listed in
+// the summary.
+func (a *auditor) auditAll(ctx context.Context, username string, summary *summaryReporter) {
+	var affiliations []string
+	if owner {
+		affiliations = []string{"OWNER"}
+	} else {
+		affiliations = []string{"OWNER", "COLLABORATOR", "ORGANIZATION_MEMBER"}
+	}
+	logrus.Debugf("Setting affiliations to %s", strings.Join(affiliations, ","))
+
+	if len(orgs) > 0 {
+		// get repos for each org
+		for _, org := range orgs {
+			logrus.Debugf("Getting repositories for org %s...", org)
+			err := a.getRepositories(ctx, affiliations, repo, org, "", true)
+			if err != nil {
+				logrus.WithError(err).Errorf("getting repositories for org %s failed", org)
+				summary.failed(org, err)
+			}
+		}
+	} else {
+		// get repos for the user only
+		logrus.Debugf("Getting repositories for user %s...", username)
+		err := a.getRepositories(ctx, affiliations, repo, username, "", false)
+		if err != nil {
+			logrus.WithError(err).Errorf("getting repositories for user %s failed", username)
+			summary.failed(username, err)
+		}
+	}
+}
+
+// auditor holds the clients and the state shared by every repository audit.
+type auditor struct {
+	restClient    *github.Client
+	graphqlClient *GQLClient
+	reporter      reporter
+	// teams caches the members of every team across repositories.
+	teams *teamCache
+	// policy is evaluated against every repository, if set.
+	policy *policy
+	// suppressions are the accepted findings, if any.
+	suppressions *suppressions
+	// hookHosts are the approved domains webhooks may deliver to.
+	hookHosts []string
+	// productionEnvs are the words marking an environment as production.
+	productionEnvs []string
+	// handle audits a single repository, it defaults to handleRepo.
+	handle func(ctx context.Context, repo ghrepo) (*repoReport, error)
+	// concurrency is the number of repositories audited in parallel.
+	concurrency int
+	// keyRepos holds, per deploy key fingerprint, the repositories reported
+	// so far that the key has access to.
+	keyRepos map[string][]string
+}
+
+func (a *auditor) getRepositories(ctx context.Context, affiliations []string, searchRepo string, login string, cursor string, isOrg bool) error {
 
 	var (
 		repos       []ghrepo
-		errors      []GQLError
+		gqlErrs     GQLErrors
 		hasNextPage bool
 		variables   = map[string]interface{}{
 			"login":        login,
@@ -176,10 +329,11 @@ func getRepositories(ctx context.Context, restClient *github.Client, graphqlClie
 			logrus.Debugf("Executing GraphQL query to fetch repos under org %s", login)
 			var data orgReposResponse
 
-			if err := graphqlClient.Execute(GQLRequest{
+			err := a.graphqlClient.Execute(GQLRequest{
 				Query:     buildGetReposQuery("organization"),
 				Variables: variables,
-			}, &data, &errors); err != nil {
+			}, &data)
+			if gqlErrs, err = partialErrors(err); err != nil {
 				return err
 			}
 
@@ -193,10 +347,11 @@ func getRepositories(ctx context.Context, restClient *github.Client, graphqlClie
 		} else {
 			logrus.Debugf("Executing GraphQL query to fetch repos under user %s", login)
 			var data userReposResponse
-			if err := graphqlClient.Execute(GQLRequest{
+			err := a.graphqlClient.Execute(GQLRequest{
 				Query:     buildGetReposQuery("user"),
 				Variables: variables,
-			}, &data, &errors); err != nil {
+			}, &data)
+			if gqlErrs, err = partialErrors(err); err != nil {
 				return err
 			}
 
@@ -211,199 +366,377 @@ func getRepositories(ctx context.Context, restClient *github.Client, graphqlClie
 	} else {
 		logrus.Debugf("Executing GraphQL query to fetch only 1 repo: %s", searchRepo)
 		var data repoResponse
-		var errors []GQLError
 
 		// get only one repo
 		search := strings.SplitN(searchRepo, "/", 2)
-		if err := graphqlClient.Execute(GQLRequest{
+		if len(search) != 2 {
+			return fmt.Errorf("repo %q must be in the form owner/name", searchRepo)
+		}
+		err := a.graphqlClient.Execute(GQLRequest{
 			Query: queryGetRepo,
 			Variables: map[string]interface{}{
 				"owner": search[0],
 				"name":  search[1],
 			},
-		}, &data, &errors); err != nil {
+		}, &data)
+		if gqlErrs, err = partialErrors(err); err != nil {
 			return err
 		}
 
-		repos = []ghrepo{data.Repository}
+		if data.Repository.NameWithOwner != "" {
+			repos = []ghrepo{data.Repository}
+		}
+	}
+
+	// Attribute the errors to the repositories they belong to.
+	repoErrs, unattributed := gqlErrs.byRepository()
+	if len(repos) < 1 && len(gqlErrs) > 0 {
+		return gqlErrs
+	}
+	if len(unattributed) > 0 {
+		logrus.WithError(unattributed).Warnf("query for %s returned errors", login)
+	}
+
+	// A repository that could not be fetched at all, like one of an
+	// organization enforcing SAML single sign-on, comes back as a null node,
+	// its errors are reported against the user or organization instead.
+	skipped := []string{}
+	for i, r := range repos {
+		if r.NameWithOwner != "" {
+			continue
+		}
+		msg := "no data was returned"
+		if len(repoErrs[i]) > 0 {
+			msg = strings.Join(repoErrs[i], "; ")
+		}
+		skipped = append(skipped, msg)
 	}
 
-	// handle each repo
-	for _, repo := range repos {
-		logrus.Debugf("Handling repo %s...", repo.Name)
-		if err := handleRepo(ctx, restClient, repo); err != nil {
-			logrus.WithError(err).Errorf("auditing %s failed", repo.NameWithOwner)
+	// handle the repos in parallel, keeping the results in query order
+	reports := make([]*repoReport, len(repos))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < a.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				reports[i] = a.auditRepo(ctx, repos[i], repoErrs[i])
+			}
+		}()
+	}
+	for i, r := range repos {
+		if r.NameWithOwner != "" {
+			jobs <- i
 		}
 	}
+	close(jobs)
+	wg.Wait()
+
+	for _, report := range reports {
+		if report == nil {
+			continue
+		}
 
+		a.addReusedKeyFindings(report)
+		logrus.Debugf("Printing details for %s", report.Repository)
+		if err := a.reporter.Report(report); err != nil {
+			return err
+		}
+	}
+
+	var err error
 	if hasNextPage {
-		return getRepositories(ctx, restClient, graphqlClient, affiliations, searchRepo, login, cursor, isOrg)
+		err = a.getRepositories(ctx, affiliations, searchRepo, login, cursor, isOrg)
 	}
 
-	return nil
+	if len(skipped) > 0 {
+		skippedErr := fmt.Errorf("%d repositories could not be fetched: %s", len(skipped), strings.Join(skipped, "; "))
+		if err != nil {
+			return fmt.Errorf("%v; %v", skippedErr, err)
+		}
+		return skippedErr
+	}
+	return err
+}
+
+// auditRepo fetches the rest of the repository and audits it, adding errs to
+// the errors of the report.
+func (a *auditor) auditRepo(ctx context.Context, repo ghrepo, errs []string) *repoReport {
+	logrus.Debugf("Handling repo %s...", repo.Name)
+	if err := paginateRepo(a.graphqlClient, &repo); err != nil {
+		logrus.WithError(err).Errorf("fetching every page of %s failed, the audit will be incomplete", repo.NameWithOwner)
+		errs = append(errs, fmt.Sprintf("fetching every page failed: %v", err))
+	}
+
+	handle := a.handle
+	if handle == nil {
+		handle = a.handleRepo
+	}
+	report, err := handle(ctx, repo)
+	if err != nil {
+		logrus.WithError(err).Errorf("auditing %s failed", repo.NameWithOwner)
+		report = newRepoReport(repo)
+		report.Errors = append(report.Errors, err.Error())
+	}
+	if report == nil && len(errs) > 0 {
+		report = newRepoReport(repo)
+	}
+	if report == nil {
+		return nil
+	}
+	report.Errors = append(errs, report.Errors...)
+
+	return report
+}
+
+// partialErrors splits the error returned by GQLClient.Execute into the
+// GraphQL errors returned alongside partial data, and any other error.
+func partialErrors(err error) (GQLErrors, error) {
+	if err == nil {
+		return nil, nil
+	}
+	if errs, ok := err.(GQLErrors); ok {
+		return errs, nil
+	}
+	return nil, err
+}
+
+// restGet fetches the REST API path into v, for the endpoints and fields
+// go-github does not support. The types v decodes into mirror the REST API
+// responses and are declared next to the audits using them.
+func (a *auditor) restGet(ctx context.Context, path string, v interface{}) (*github.Response, error) {
+	req, err := a.restClient.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return waitRateLimit(ctx, func() (*github.Response, error) {
+		return a.restClient.Do(ctx, req, v)
+	})
+}
+
+// isNotVisible reports whether err is the 403 or 404 the REST API answers
+// with for what the token is not allowed to see, like the admin-only
+// endpoints of a repository the token cannot administer.
+func isNotVisible(err error) bool {
+	rerr, ok := err.(*github.ErrorResponse)
+	if !ok || rerr.Response == nil {
+		return false
+	}
+	return rerr.Response.StatusCode == http.StatusForbidden || rerr.Response.StatusCode == http.StatusNotFound
+}
+
+// newRepoReport returns an empty report for the repository.
+func newRepoReport(repo ghrepo) *repoReport {
+	return &repoReport{
+		Repository:          repo.NameWithOwner,
+		URL:                 "https://github.com/" + repo.NameWithOwner,
+		Private:             repo.IsPrivate,
+		DefaultBranch:       repo.DefaultBranchRef.Name,
+		Collaborators:       []collaboratorReport{},
+		DeployKeys:          []deployKeyReport{},
+		Hooks:               []hookReport{},
+		ProtectionRules:     []protectionRuleReport{},
+		UnprotectedBranches: []string{},
+		MergeMethods:        []string{},
+		Workflows:           []workflowReport{},
+		Environments:        []environmentReport{},
+		Findings:            []finding{},
+		NotVisible:          []string{},
+		Errors:              []string{},
+	}
+}
+
+// addError records that what could not be fetched, in the errors of the
+// report, or as not visible when the token is not allowed to see it.
+func (r *repoReport) addError(what string, err error) {
+	if isNotVisible(err) {
+		r.NotVisible = append(r.NotVisible, what)
+		return
+	}
+	r.Errors = append(r.Errors, fmt.Sprintf("fetching %s failed: %v", what, err))
 }
 
-// handleRepo will return nil error if the user does not have access to something.
-func handleRepo(ctx context.Context, restClient *github.Client, repo ghrepo) error {
+// handleRepo audits the repository. Anything that could not be audited, for
+// example because the user does not have access to it, is recorded in the
+// errors of the report.
+// A nil report is returned when there is nothing worth reporting on the repo.
+func (a *auditor) handleRepo(ctx context.Context, repo ghrepo) (*repoReport, error) {
 	opt := &github.ListOptions{
 		PerPage: 100,
 	}
 
+	report := newRepoReport(repo)
+
 	logrus.Debugf("Executing REST query to list teams for %s", repo.NameWithOwner)
-	teams, resp, err := restClient.Repositories.ListTeams(ctx, repo.Owner.Login, repo.Name, opt)
-	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden || err != nil {
-		if _, ok := err.(*github.RateLimitError); ok {
-			return err
+	var teams []*github.Team
+	_, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+		teams, resp, err = a.restClient.Repositories.ListTeams(ctx, repo.Owner.Login, repo.Name, opt)
+		return resp, err
+	})
+	if err != nil {
+		if isRateLimit(err) {
+			return nil, err
 		}
 
-		return nil
-	}
-	if err != nil {
-		return err
+		report.Errors = append(report.Errors, fmt.Sprintf("listing teams failed: %v", err))
 	}
 
 	logrus.Debugf("Executing REST query to list hooks for %s", repo.NameWithOwner)
-	hooks, resp, err := restClient.Repositories.ListHooks(ctx, repo.Owner.Login, repo.Name, opt)
-	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden || err != nil {
-		if _, ok := err.(*github.RateLimitError); ok {
-			return err
+	hooks, err := a.listHooks(ctx, repoHooksPath(repo))
+	if err != nil {
+		if isRateLimit(err) {
+			return nil, err
 		}
 
-		return nil
-	}
-	if err != nil {
-		return err
+		report.Errors = append(report.Errors, fmt.Sprintf("listing hooks failed: %v", err))
 	}
 
 	// only print whole status if we have more that one collaborator
-	if repo.Collaborators.TotalCount <= 1 && repo.DeployKeys.TotalCount < 1 && len(hooks) < 1 && repo.BranchProtectionRules.TotalCount < 1 && repo.Refs.TotalCount < 1 {
-		return nil
+	if repo.Collaborators.TotalCount <= 1 && repo.DeployKeys.TotalCount < 1 && len(hooks) < 1 && repo.BranchProtectionRules.TotalCount < 1 && repo.Refs.TotalCount < 1 && len(report.Errors) < 1 {
+		return nil, nil
 	}
 
-	output := fmt.Sprintf("%s -> \n", repo.NameWithOwner)
+	bySlug := teamsBySlug(teams)
+	for _, c := range repo.Collaborators.Edges {
+		via, errs := a.teams.inheritedVia(ctx, bySlug, c)
+		report.Errors = append(report.Errors, errs...)
+		report.Collaborators = append(report.Collaborators, explainPermission(c, bySlug, via))
+	}
 
-	if repo.Collaborators.TotalCount > 1 {
-		admin := []string{}
-		maintain := []string{}
-		pull := []string{}
-		push := []string{}
-		triage := []string{}
+	for _, k := range repo.DeployKeys.Nodes {
+		key, err := newDeployKeyReport(repo, k)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		report.DeployKeys = append(report.DeployKeys, key)
+	}
 
-		logrus.Debugf("Executing REST query to check collaborators' team memberships for %s", repo.NameWithOwner)
-		for _, c := range repo.Collaborators.Edges {
-			userTeams := []github.Team{}
-			for _, t := range teams {
-				isMember, resp, err := restClient.Teams.GetTeamMembership(ctx, t.GetID(), c.Node.Login)
-				if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusForbidden && err == nil && isMember.GetState() == "active" {
-					userTeams = append(userTeams, *t)
-				}
-			}
+	hookReports, errs, err := a.auditHooks(ctx, repoHooksPath(repo), hooks)
+	if err != nil {
+		return nil, err
+	}
+	report.Hooks = append(report.Hooks, hookReports...)
+	report.Errors = append(report.Errors, errs...)
 
-			switch c.Permission {
-			case "ADMIN":
-				permTeams := []string{}
-				for _, t := range userTeams {
-					if t.GetPermission() == "push" {
-						permTeams = append(permTeams, t.GetName())
-					}
-				}
-				admin = append(admin, fmt.Sprintf("\t\t\t%s (teams: %s)", c.Node.Login, strings.Join(permTeams, ", ")))
-			case "MAINTAIN":
-				permTeams := []string{}
-				for _, t := range userTeams {
-					if t.GetPermission() == "push" {
-						permTeams = append(permTeams, t.GetName())
-					}
-				}
-				maintain = append(maintain, fmt.Sprintf("\t\t\t%s (teams: %s)", c.Node.Login, strings.Join(permTeams, ", ")))
-			case "TRIAGE":
-				permTeams := []string{}
-				for _, t := range userTeams {
-					if t.GetPermission() == "pull" {
-						permTeams = append(permTeams, t.GetName())
-					}
-				}
-				triage = append(triage, fmt.Sprintf("\t\t\t%s (teams: %s)", c.Node.Login, strings.Join(permTeams, ", ")))
-			case "WRITE":
-				permTeams := []string{}
-				for _, t := range userTeams {
-					if t.GetPermission() == "push" {
-						permTeams = append(permTeams, t.GetName())
-					}
-				}
-				push = append(push, fmt.Sprintf("\t\t\t%s (teams: %s)", c.Node.Login, strings.Join(permTeams, ", ")))
-			case "READ":
-				permTeams := []string{}
-				for _, t := range userTeams {
-					if t.GetPermission() == "pull" {
-						permTeams = append(permTeams, t.GetName())
-					}
-				}
-				pull = append(pull, fmt.Sprintf("\t\t\t%s (teams: %s)", c.Node.Login, strings.Join(permTeams, ", ")))
-			}
-		}
-		output += fmt.Sprintf("\tCollaborators (%d):\n", repo.Collaborators.TotalCount)
-		output += fmt.Sprintf("\t\tAdmin (%d):\n%s\n", len(admin), strings.Join(admin, "\n"))
-		output += fmt.Sprintf("\t\tMaintain (%d):\n%s\n", len(maintain), strings.Join(maintain, "\n"))
-		output += fmt.Sprintf("\t\tTriage (%d):\n%s\n", len(triage), strings.Join(triage, "\n"))
-		output += fmt.Sprintf("\t\tWrite (%d):\n%s\n", len(push), strings.Join(push, "\n"))
-		output += fmt.Sprintf("\t\tRead (%d):\n%s\n", len(pull), strings.Join(pull, "\n"))
+	if err := a.auditActions(ctx, repo, report); err != nil {
+		return nil, err
 	}
 
-	if repo.DeployKeys.TotalCount > 0 {
-		kstr := []string{}
-		for _, k := range repo.DeployKeys.Nodes {
-			keyURL, err := buildDeployKeyURL(repo.Owner.Login, repo.Name, k.ID)
-			if err != nil {
-				kstr = append(kstr, fmt.Sprintf("\t\t%s - ro:%t", k.Title, k.ReadOnly))
-			} else {
-				kstr = append(kstr, fmt.Sprintf("\t\t%s - ro:%t (%s)", k.Title, k.ReadOnly, keyURL))
-			}
-		}
-		output += fmt.Sprintf("\tKeys (%d):\n%s\n", repo.DeployKeys.TotalCount, strings.Join(kstr, "\n"))
+	report.Workflows, errs, err = a.auditWorkflows(ctx, repo)
+	if err != nil {
+		return nil, err
 	}
+	report.Errors = append(report.Errors, errs...)
 
-	if len(hooks) > 0 {
-		hstr := []string{}
-		for _, h := range hooks {
-			hstr = append(hstr, fmt.Sprintf("\t\t%s - active:%t (%s)", h.GetName(), h.GetActive(), h.GetURL()))
-		}
-		output += fmt.Sprintf("\tHooks (%d):\n%s\n", len(hstr), strings.Join(hstr, "\n"))
+	if err := a.auditEnvironments(ctx, repo, report); err != nil {
+		return nil, err
 	}
 
-	if repo.BranchProtectionRules.TotalCount > 0 {
-		protectedBranches := []string{}
-		for _, r := range repo.BranchProtectionRules.Nodes {
-			protectedBranches = append(protectedBranches, r.Pattern)
+	// effectiveRule needs the rules in the order they were created, which the
+	// connection does not promise, the database ids of the rules grow with
+	// their creation.
+	sort.SliceStable(repo.BranchProtectionRules.Nodes, func(i, j int) bool {
+		return repo.BranchProtectionRules.Nodes[i].DatabaseID < repo.BranchProtectionRules.Nodes[j].DatabaseID
+	})
+	for _, r := range repo.BranchProtectionRules.Nodes {
+		rule := protectionRuleReport{
+			Pattern:                     r.Pattern,
+			MatchingBranches:            []string{},
+			DismissesStaleReviews:       r.DismissesStaleReviews,
+			RequiresCodeOwnerReviews:    r.RequiresCodeOwnerReviews,
+			RequiredStatusCheckContexts: []string{},
+			EnforceForAdmins:            r.IsAdminEnforced,
+			AllowsForcePushes:           r.AllowsForcePushes,
+			AllowsDeletions:             r.AllowsDeletions,
+			RequiresLinearHistory:       r.RequiresLinearHistory,
+			RequiresSignatures:          r.RequiresCommitSignatures,
+			RestrictsPushes:             r.RestrictsPushes,
+			PushAllowances:              []string{},
 		}
-		output += fmt.Sprintf("\tProtected Branches (%d): %s\n", len(protectedBranches), strings.Join(protectedBranches, ", "))
+		// The review count is only meaningful when reviews are required.
+		if r.RequiresApprovingReviews {
+			rule.RequiredApprovingReviewCount = r.RequiredApprovingReviewCount
+		}
+		if r.RequiresStatusChecks {
+			rule.RequiredStatusCheckContexts = append(rule.RequiredStatusCheckContexts, r.RequiredStatusCheckContexts...)
+		}
+		for _, a := range r.PushAllowances.Nodes {
+			rule.PushAllowances = append(rule.PushAllowances, a.Actor.name())
+		}
+		for _, ref := range repo.Refs.Nodes {
+			if matchBranchPattern(r.Pattern, ref.Name) {
+				rule.MatchingBranches = append(rule.MatchingBranches, ref.Name)
+			}
+		}
+		report.ProtectionRules = append(report.ProtectionRules, rule)
 	}
 
-	if repo.Refs.TotalCount > 0 {
-		unprotectedBranches := []string{}
-		for _, r := range repo.Refs.Nodes {
-			unprotectedBranches = append(unprotectedBranches, r.Name)
+	for _, r := range repo.Refs.Nodes {
+		if len(protectingRules(report.ProtectionRules, r.Name)) < 1 {
+			report.UnprotectedBranches = append(report.UnprotectedBranches, r.Name)
 		}
-		output += fmt.Sprintf("\tUnprotected Branches (%d): %s\n", len(unprotectedBranches), strings.Join(unprotectedBranches, ", "))
 	}
+	report.DefaultBranchProtected = len(protectingRules(report.ProtectionRules, report.DefaultBranch)) > 0
 
-	mergeMethods := "\tMerge Methods:"
 	if repo.MergeCommitAllowed {
-		mergeMethods += " mergeCommit"
+		report.MergeMethods = append(report.MergeMethods, "mergeCommit")
 	}
 	if repo.SquashMergeAllowed {
-		mergeMethods += " squash"
+		report.MergeMethods = append(report.MergeMethods, "squash")
 	}
 	if repo.RebaseMergeAllowed {
-		mergeMethods += " rebase"
+		report.MergeMethods = append(report.MergeMethods, "rebase")
 	}
-	output += mergeMethods + "\n"
 
-	logrus.Debugf("Printing details for %s", repo.NameWithOwner)
+	report.Findings = repoFindings(report)
+	if a.policy != nil {
+		results, findings := a.policy.evaluate(report)
+		report.Policy = results
+		report.Findings = append(report.Findings, findings...)
+	}
+	if a.suppressions != nil {
+		a.suppressions.apply(report, time.Now())
+	}
 
-	fmt.Printf("%s--\n\n", output)
+	return report, nil
+}
 
-	return nil
+// addReusedKeyFindings adds a finding for every deploy key of the report that
+// was already found on another repository. Reports go out in order, so the key
+// is flagged on every repository but the first one it was found on.
+func (a *auditor) addReusedKeyFindings(r *repoReport) {
+	if a.keyRepos == nil {
+		a.keyRepos = map[string][]string{}
+	}
+
+	findings := []finding{}
+	for _, k := range r.DeployKeys {
+		if k.Fingerprint == "" {
+			continue
+		}
+		repos := a.keyRepos[k.Fingerprint]
+		others := []string{}
+		for _, repo := range repos {
+			if repo != r.Repository {
+				others = append(others, repo)
+			}
+		}
+		if len(others) > 0 {
+			findings = append(findings, newFinding(ruleDeployKeyReused, r.Repository, k.ID,
+				fmt.Sprintf("deploy key %q (%s) is also used on %s", k.Title, k.Fingerprint, strings.Join(others, ", "))))
+		}
+		if !contains(repos, r.Repository) {
+			a.keyRepos[k.Fingerprint] = append(repos, r.Repository)
+		}
+	}
+
+	if a.suppressions != nil {
+		var suppressed []finding
+		findings, suppressed = a.suppressions.filter(findings, time.Now())
+		r.Suppressed = append(r.Suppressed, suppressed...)
+	}
+	r.Findings = append(r.Findings, findings...)
 }
 
 func buildDeployKeyURL(owner, name, id string) (string, error) {
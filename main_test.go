@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddReusedKeyFindings(t *testing.T) {
+	a := &auditor{}
+	shared := "SHA256:iC+KvAuEp8pDuR3EYEWzlz7RVtCPY5nKXmOyohMNsZQ"
+	reports := []*repoReport{
+		{Repository: "genuinetools/audit", DeployKeys: []deployKeyReport{
+			{ID: "DK_1", Title: "ci", Fingerprint: shared},
+			{ID: "DK_2", Title: "deploy", Fingerprint: "SHA256:J/iIWaE8KEhk4tYg8IbAmSU46Nuo4So4OFgo6IHwA58"},
+		}},
+		{Repository: "genuinetools/img", DeployKeys: []deployKeyReport{
+			{ID: "DK_3", Title: "ci", Fingerprint: shared},
+			{ID: "DK_4", Title: "unparsed"},
+		}},
+		{Repository: "genuinetools/reg", DeployKeys: []deployKeyReport{
+			{ID: "DK_5", Title: "ci too", Fingerprint: shared},
+		}},
+	}
+
+	var got []string
+	for _, r := range reports {
+		a.addReusedKeyFindings(r)
+		for _, f := range r.Findings {
+			got = append(got, f.fingerprint()+" "+f.Message)
+		}
+	}
+
+	want := []string{
+		`genuinetools/img:deploy-key-reused:DK_3 deploy key "ci" (` + shared + `) is also used on genuinetools/audit`,
+		`genuinetools/reg:deploy-key-reused:DK_5 deploy key "ci too" (` + shared + `) is also used on genuinetools/audit, genuinetools/img`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings =\n%v\nwant\n%v", got, want)
+	}
+}
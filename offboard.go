@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+const offboardHelp = `Find the access users who left still have.`
+
+type offboardCommand struct{}
+
+func (cmd *offboardCommand) Name() string      { return "offboard" }
+func (cmd *offboardCommand) Args() string      { return "[OPTIONS] USERS_FILE" }
+func (cmd *offboardCommand) ShortHelp() string { return offboardHelp }
+func (cmd *offboardCommand) LongHelp() string {
+	return offboardHelp + `
+
+USERS_FILE lists one departed user per line, either as a login or as an email
+followed by the login it maps to. Empty lines and lines starting with # are
+ignored:
+
+	octocat
+	jane@example.com janedoe
+
+For every user, the organization memberships, team memberships, repository
+collaborator entries and deploy keys whose title mentions them are reported.
+The command exits with 2 if any access is left. Use -format json to get the
+result as JSON.`
+}
+func (cmd *offboardCommand) Hidden() bool { return false }
+
+func (cmd *offboardCommand) Register(fs *flag.FlagSet) {}
+
+func (cmd *offboardCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("offboard takes exactly one file of departed users")
+	}
+
+	if err := checkAuditFlags(); err != nil {
+		return err
+	}
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("unknown offboard format %q (must be one of: %s, %s)", format, formatText, formatJSON)
+	}
+
+	users, err := loadDepartedUsers(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx = handleSignals(ctx)
+
+	sweep := newOffboardReporter(os.Stdout, format == formatJSON, users)
+	summary := &summaryReporter{reporter: sweep, w: os.Stderr}
+
+	a, username, err := newAuditor(ctx, summary)
+	if err != nil {
+		return err
+	}
+	a.handle = sweep.handler(a)
+
+	a.auditAll(ctx, username, summary)
+	sweep.checkOrganizations(ctx, a)
+	if err := summary.Close(); err != nil {
+		return err
+	}
+
+	code := summary.exitCode("")
+	if sweep.incomplete() {
+		code = exitIncomplete
+	}
+	if sweep.residualAccess() {
+		code = exitFindings
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
+// departedUser is a user who left.
+type departedUser struct {
+	Login string `json:"login"`
+	Email string `json:"email,omitempty"`
+
+	// mention matches the login, or the local part of the email, as a whole
+	// word.
+	mention *regexp.Regexp
+}
+
+// newDepartedUser returns the departed user with the login and email, which
+// may be empty.
+func newDepartedUser(login, email string) departedUser {
+	names := []string{regexp.QuoteMeta(login)}
+	if i := strings.Index(email, "@"); i > 0 {
+		names = append(names, regexp.QuoteMeta(email[:i]))
+	}
+	return departedUser{
+		Login:   login,
+		Email:   email,
+		mention: regexp.MustCompile(`(?i)(^|[^A-Za-z0-9])(` + strings.Join(names, "|") + `)($|[^A-Za-z0-9])`),
+	}
+}
+
+// loadDepartedUsers reads the file of departed users at path.
+func loadDepartedUsers(path string) ([]departedUser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading users file failed: %v", err)
+	}
+	defer f.Close()
+
+	users := []departedUser{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 1 && !strings.Contains(fields[0], "@"):
+			users = append(users, newDepartedUser(fields[0], ""))
+		case len(fields) == 2 && strings.Contains(fields[0], "@"):
+			users = append(users, newDepartedUser(fields[1], fields[0]))
+		default:
+			return nil, fmt.Errorf("line %d of users file %s must be a login, or an email followed by a login", n, path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading users file failed: %v", err)
+	}
+	if len(users) < 1 {
+		return nil, fmt.Errorf("users file %s is empty", path)
+	}
+	return users, nil
+}
+
+// mentionedBy reports whether the title of a deploy key mentions the user,
+// by login or by the local part of their email. Only whole words count, so a
+// short login like "dev" does not match "developer", but anything other than
+// a letter or a digit separates words: a key titled "jess-laptop" mentions
+// jess, better a key too many than a key left behind.
+func (u departedUser) mentionedBy(title string) bool {
+	return u.mention.MatchString(title)
+}
+
+// residualAccess is the access a departed user still has.
+type residualAccess struct {
+	departedUser
+	Organizations []string        `json:"organizations"`
+	Teams         []string        `json:"teams"`
+	Repositories  []userAccess    `json:"repositories"`
+	DeployKeys    []userDeployKey `json:"deployKeys"`
+	// Errors holds everything that could not be checked for the user.
+	Errors []string `json:"errors"`
+}
+
+// userDeployKey is a deploy key whose title mentions a user.
+type userDeployKey struct {
+	Repository string `json:"repository"`
+	deployKeyReport
+}
+
+// any reports whether the user still has any access.
+func (r *residualAccess) any() bool {
+	return len(r.Organizations) > 0 || len(r.Teams) > 0 || len(r.Repositories) > 0 || len(r.DeployKeys) > 0
+}
+
+// offboardReporter is a reporter collecting the residual access of departed
+// users, written on Close.
+type offboardReporter struct {
+	w    io.Writer
+	json bool
+
+	users  []*residualAccess
+	access *accessReporter
+
+	mu sync.Mutex
+	// orgs holds the organizations owning the audited repositories.
+	orgs map[string]bool
+}
+
+// newOffboardReporter returns an offboardReporter for the users.
+func newOffboardReporter(w io.Writer, json bool, users []departedUser) *offboardReporter {
+	o := &offboardReporter{
+		w:      w,
+		json:   json,
+		access: &accessReporter{access: map[string][]userAccess{}},
+		orgs:   map[string]bool{},
+	}
+	for _, u := range users {
+		o.users = append(o.users, &residualAccess{
+			departedUser:  u,
+			Organizations: []string{},
+			Teams:         []string{},
+			DeployKeys:    []userDeployKey{},
+			Errors:        []string{},
+		})
+		o.access.logins = append(o.access.logins, u.Login)
+	}
+	for _, l := range orgs {
+		o.orgs[l] = true
+	}
+	return o
+}
+
+// handler returns the handler auditing the access of the users to a
+// repository: their collaborator entries and the deploy keys mentioning them.
+func (o *offboardReporter) handler(a *auditor) func(ctx context.Context, repo ghrepo) (*repoReport, error) {
+	handleUsers := a.handleUsers(o.access.logins)
+
+	return func(ctx context.Context, repo ghrepo) (*repoReport, error) {
+		if repo.Owner.Typename == "Organization" {
+			o.mu.Lock()
+			o.orgs[repo.Owner.Login] = true
+			o.mu.Unlock()
+		}
+
+		report, err := handleUsers(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range repo.DeployKeys.Nodes {
+			for _, u := range o.users {
+				if !u.mentionedBy(k.Title) {
+					continue
+				}
+				if report == nil {
+					report = newRepoReport(repo)
+				}
+				key, err := newDeployKeyReport(repo, k)
+				if err != nil {
+					report.Errors = append(report.Errors, err.Error())
+				}
+				report.DeployKeys = append(report.DeployKeys, key)
+				break
+			}
+		}
+
+		return report, nil
+	}
+}
+
+func (o *offboardReporter) Report(r *repoReport) error {
+	if err := o.access.Report(r); err != nil {
+		return err
+	}
+	for _, k := range r.DeployKeys {
+		for _, u := range o.users {
+			if u.mentionedBy(k.Title) {
+				u.DeployKeys = append(u.DeployKeys, userDeployKey{Repository: r.Repository, deployKeyReport: k})
+			}
+		}
+	}
+	return nil
+}
+
+// checkOrganizations looks up the organization and team memberships of the
+// users in every organization passed with -orgs or owning an audited
+// repository.
+func (o *offboardReporter) checkOrganizations(ctx context.Context, a *auditor) {
+	names := []string{}
+	for org := range o.orgs {
+		names = append(names, org)
+	}
+	sort.Strings(names)
+
+	membership := newOrgMembership(a.restClient)
+	for _, org := range names {
+		teams, err := listOrgTeams(ctx, a.restClient, org)
+		if err != nil {
+			logrus.WithError(err).Errorf("listing teams of %s failed", org)
+		}
+
+		for _, u := range o.users {
+			if err != nil {
+				u.Errors = append(u.Errors, fmt.Sprintf("listing teams of %s failed: %v", org, err))
+			}
+
+			member, merr := membership.isMember(ctx, org, u.Login)
+			if merr != nil {
+				u.Errors = append(u.Errors, fmt.Sprintf("checking membership of %s failed: %v", org, merr))
+			} else if member {
+				u.Organizations = append(u.Organizations, org)
+			}
+
+			for _, t := range teams {
+				isMember, terr := a.teams.isMember(ctx, t.GetID(), u.Login)
+				if terr != nil {
+					u.Errors = append(u.Errors, fmt.Sprintf("listing members of team %s/%s failed: %v", org, t.GetSlug(), terr))
+					continue
+				}
+				if isMember {
+					u.Teams = append(u.Teams, org+"/"+t.GetSlug())
+				}
+			}
+		}
+	}
+}
+
+// listOrgTeams lists every team of the organization.
+func listOrgTeams(ctx context.Context, restClient *github.Client, org string) ([]*github.Team, error) {
+	logrus.Debugf("Executing REST query to list teams of %s", org)
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	teams := []*github.Team{}
+	for {
+		var page []*github.Team
+		resp, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			page, resp, err = restClient.Teams.ListTeams(ctx, org, opt)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return teams, nil
+}
+
+// residualAccess reports whether any user still has access.
+func (o *offboardReporter) residualAccess() bool {
+	for _, u := range o.users {
+		if u.any() {
+			return true
+		}
+	}
+	return false
+}
+
+// incomplete reports whether anything could not be checked for a user.
+func (o *offboardReporter) incomplete() bool {
+	for _, u := range o.users {
+		if len(u.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *offboardReporter) Close() error {
+	for i, access := range o.access.users() {
+		o.users[i].Repositories = access.Repositories
+	}
+
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Users []*residualAccess `json:"users"`
+		}{o.users})
+	}
+
+	for _, u := range o.users {
+		output := fmt.Sprintf("%s -> \n", u.Login)
+		if u.Email != "" {
+			output = fmt.Sprintf("%s (%s) -> \n", u.Login, u.Email)
+		}
+		if !u.any() {
+			output += "\tNo access left\n"
+		}
+
+		if len(u.Organizations) > 0 {
+			output += fmt.Sprintf("\tOrganizations (%d): %s\n", len(u.Organizations), strings.Join(u.Organizations, ", "))
+		}
+		if len(u.Teams) > 0 {
+			output += fmt.Sprintf("\tTeams (%d): %s\n", len(u.Teams), strings.Join(u.Teams, ", "))
+		}
+
+		if len(u.Repositories) > 0 {
+			rstr := []string{}
+			for _, r := range u.Repositories {
+				sources := []string{}
+				for _, s := range r.Sources {
+					sources = append(sources, s.String())
+				}
+				line := fmt.Sprintf("\t\t%s - %s (%s)", r.Repository, r.Permission, strings.Join(sources, ", "))
+				if r.OutsideCollaborator {
+					line += " [outside collaborator]"
+				}
+				rstr = append(rstr, line)
+			}
+			output += fmt.Sprintf("\tRepositories (%d):\n%s\n", len(rstr), strings.Join(rstr, "\n"))
+		}
+
+		if len(u.DeployKeys) > 0 {
+			kstr := []string{}
+			for _, k := range u.DeployKeys {
+				line := fmt.Sprintf("\t\t%s - %s - ro:%t", k.Repository, k.Title, k.ReadOnly)
+				if k.URL != "" {
+					line += fmt.Sprintf(" (%s)", k.URL)
+				}
+				kstr = append(kstr, line)
+			}
+			output += fmt.Sprintf("\tDeploy Keys (%d):\n%s\n", len(kstr), strings.Join(kstr, "\n"))
+		}
+
+		if len(u.Errors) > 0 {
+			estr := []string{}
+			for _, e := range u.Errors {
+				estr = append(estr, "\t\t"+e)
+			}
+			output += fmt.Sprintf("\tErrors (%d):\n%s\n", len(estr), strings.Join(estr, "\n"))
+		}
+
+		if _, err := fmt.Fprintf(o.w, "%s--\n\n", output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
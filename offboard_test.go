@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestMentionedBy(t *testing.T) {
+	tests := []struct {
+		user  departedUser
+		title string
+		want  bool
+	}{
+		{newDepartedUser("jess", ""), "jess laptop", true},
+		{newDepartedUser("jess", ""), "Deploy key (Jess)", true},
+		{newDepartedUser("jess", ""), "jessica laptop", false},
+		{newDepartedUser("dev", ""), "developer machine", false},
+		{newDepartedUser("dev", ""), "dev-box", true},
+		{newDepartedUser("jess", ""), "jess-laptop", true},
+		{newDepartedUser("jess", ""), "ci-jess", true},
+		{newDepartedUser("jess", ""), "jess-f", true},
+		{newDepartedUser("dev", ""), "key for dev_box", true},
+		{newDepartedUser("ops", ""), "ops@build", true},
+		{newDepartedUser("ops", ""), "devops", false},
+		{newDepartedUser("jf", "jane.doe@example.com"), "jane.doe's key", true},
+		{newDepartedUser("jf", "jane.doe@example.com"), "janexdoe", false},
+		{newDepartedUser("jf", "jane.doe@example.com"), "jf-ci", true},
+		{newDepartedUser("jf", "jane.doe@example.com"), "buildjf", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.user.mentionedBy(tt.title); got != tt.want {
+			t.Errorf("%s (%s) mentionedBy(%q) = %t, want %t", tt.user.Login, tt.user.Email, tt.title, got, tt.want)
+		}
+	}
+}
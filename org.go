@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+// orgSettings are the settings of an organization. Most of them are only
+// returned to the owners of the organization.
+type orgSettings struct {
+	Login                               string  `json:"login"`
+	HTMLURL                             string  `json:"html_url"`
+	DefaultRepositoryPermission         *string `json:"default_repository_permission"`
+	MembersCanCreatePublicRepositories  *bool   `json:"members_can_create_public_repositories"`
+	MembersCanCreatePrivateRepositories *bool   `json:"members_can_create_private_repositories"`
+	MembersCanForkPrivateRepositories   *bool   `json:"members_can_fork_private_repositories"`
+	TwoFactorRequirementEnabled         *bool   `json:"two_factor_requirement_enabled"`
+}
+
+// auditOrgs audits the settings of every organization passed with -orgs,
+// recording the ones that could not be audited at all in the summary.
+func (a *auditor) auditOrgs(ctx context.Context, summary *summaryReporter) {
+	for _, org := range orgs {
+		logrus.Debugf("Auditing settings of org %s...", org)
+		report, err := a.auditOrg(ctx, org)
+		if err != nil {
+			logrus.WithError(err).Errorf("auditing org %s failed", org)
+			summary.failed(org, err)
+			continue
+		}
+		if a.suppressions != nil {
+			report.Findings, report.Suppressed = a.suppressions.filter(report.Findings, time.Now())
+		}
+
+		logrus.Debugf("Printing details for org %s", org)
+		if err := summary.ReportOrg(report); err != nil {
+			logrus.WithError(err).Errorf("reporting org %s failed", org)
+		}
+	}
+}
+
+// auditOrg audits the settings of the organization. An error is returned
+// when the organization cannot be fetched at all, anything else that could not
+// be audited is recorded in the errors of the report.
+func (a *auditor) auditOrg(ctx context.Context, org string) (*orgReport, error) {
+	logrus.Debugf("Executing REST query to get org %s", org)
+	var settings orgSettings
+	_, err := a.restGet(ctx, "orgs/"+org, &settings)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newOrgReport(settings)
+	if settings.DefaultRepositoryPermission == nil {
+		report.NotVisible = append(report.NotVisible, "settings")
+	}
+
+	logrus.Debugf("Executing REST query to list owners of %s", org)
+	report.Owners, err = a.listOrgMembers(ctx, org, &github.ListMembersOptions{Role: "admin"})
+	if err != nil {
+		if isRateLimit(err) {
+			return nil, err
+		}
+		report.addError("owners", err)
+	}
+
+	logrus.Debugf("Executing REST query to list members of %s with 2FA disabled", org)
+	report.MembersWithoutTwoFactor, err = a.listOrgMembers(ctx, org, &github.ListMembersOptions{Filter: "2fa_disabled"})
+	if err != nil {
+		if isRateLimit(err) {
+			return nil, err
+		}
+		// Only owners can filter the members by 2FA, GitHub answers
+		// everyone else with a 422.
+		if rerr, ok := err.(*github.ErrorResponse); ok && rerr.Response != nil && rerr.Response.StatusCode == http.StatusUnprocessableEntity {
+			report.NotVisible = append(report.NotVisible, "members with 2FA disabled")
+		} else {
+			report.addError("members with 2FA disabled", err)
+		}
+	}
+
+	logrus.Debugf("Executing REST query to list outside collaborators of %s", org)
+	report.OutsideCollaborators, err = a.countOutsideCollaborators(ctx, org)
+	if err != nil {
+		if isRateLimit(err) {
+			return nil, err
+		}
+		report.addError("outside collaborators", err)
+	}
+
+	logrus.Debugf("Executing REST query to list hooks for org %s", org)
+	hooks, err := a.listHooks(ctx, orgHooksPath(org))
+	if err != nil {
+		if isRateLimit(err) {
+			return nil, err
+		}
+		report.addError("hooks", err)
+	}
+	hookReports, errs, err := a.auditHooks(ctx, orgHooksPath(org), hooks)
+	if err != nil {
+		return nil, err
+	}
+	report.Hooks = append(report.Hooks, hookReports...)
+	report.Errors = append(report.Errors, errs...)
+
+	logrus.Debugf("Executing GraphQL query to fetch domains of org %s", org)
+	var data orgDomainsResponse
+	if err := a.graphqlClient.Execute(GQLRequest{
+		Query:     queryGetOrgDomains,
+		Variables: map[string]interface{}{"login": org},
+	}, &data); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("listing domains failed: %v", err))
+	}
+	for _, d := range data.Org.Domains.Nodes {
+		if d.IsVerified {
+			report.VerifiedDomains = append(report.VerifiedDomains, d.Domain)
+		}
+	}
+
+	if err := a.auditInstallations(ctx, org, report); err != nil {
+		return nil, err
+	}
+	a.auditOAuthApps(ctx, org, report)
+
+	report.Findings = orgFindings(report)
+	return report, nil
+}
+
+// newOrgReport returns the report of the organization with its settings.
+func newOrgReport(settings orgSettings) *orgReport {
+	report := &orgReport{
+		Organization:                        settings.Login,
+		URL:                                 settings.HTMLURL,
+		MembersCanCreatePublicRepositories:  settings.MembersCanCreatePublicRepositories,
+		MembersCanCreatePrivateRepositories: settings.MembersCanCreatePrivateRepositories,
+		MembersCanForkPrivateRepositories:   settings.MembersCanForkPrivateRepositories,
+		TwoFactorRequirementEnabled:         settings.TwoFactorRequirementEnabled,
+		Owners:                              []string{},
+		MembersWithoutTwoFactor:             []string{},
+		Hooks:                               []hookReport{},
+		VerifiedDomains:                     []string{},
+		Installations:                       []installationReport{},
+		ApprovedOAuthApps:                   []string{},
+		Findings:                            []finding{},
+		NotVisible:                          []string{},
+		Errors:                              []string{},
+	}
+	if settings.DefaultRepositoryPermission != nil {
+		report.DefaultRepositoryPermission = *settings.DefaultRepositoryPermission
+	}
+	return report
+}
+
+// addError records that what could not be listed, in the errors of the
+// report, or as not visible when the token is not allowed to see it.
+func (o *orgReport) addError(what string, err error) {
+	if isNotVisible(err) {
+		o.NotVisible = append(o.NotVisible, what)
+		return
+	}
+	o.Errors = append(o.Errors, fmt.Sprintf("listing %s failed: %v", what, err))
+}
+
+// listOrgMembers lists the logins of the members of the organization matching
+// opt, sorted.
+func (a *auditor) listOrgMembers(ctx context.Context, org string, opt *github.ListMembersOptions) ([]string, error) {
+	opt.PerPage = 100
+
+	logins := []string{}
+	for {
+		var members []*github.User
+		resp, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			members, resp, err = a.restClient.Organizations.ListMembers(ctx, org, opt)
+			return resp, err
+		})
+		if err != nil {
+			return []string{}, err
+		}
+		for _, m := range members {
+			logins = append(logins, m.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	sort.Strings(logins)
+	return logins, nil
+}
+
+// countOutsideCollaborators returns the number of outside collaborators of
+// the organization.
+func (a *auditor) countOutsideCollaborators(ctx context.Context, org string) (int, error) {
+	opt := &github.ListOutsideCollaboratorsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	count := 0
+	for {
+		var users []*github.User
+		resp, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			users, resp, err = a.restClient.Organizations.ListOutsideCollaborators(ctx, org, opt)
+			return resp, err
+		})
+		if err != nil {
+			return 0, err
+		}
+		count += len(users)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return count, nil
+}
+
+// orgFindings runs the organization rules against an organization report.
+func orgFindings(o *orgReport) []finding {
+	findings := []finding{}
+
+	if o.TwoFactorRequirementEnabled != nil && !*o.TwoFactorRequirementEnabled {
+		findings = append(findings, newFinding(ruleOrgTwoFactorNotRequired, o.Organization, "two_factor_requirement_enabled",
+			"two-factor authentication is not required for members"))
+	}
+	for _, m := range o.MembersWithoutTwoFactor {
+		findings = append(findings, newFinding(ruleOrgMemberWithoutTwoFactor, o.Organization, m,
+			fmt.Sprintf("%s has two-factor authentication disabled", m)))
+	}
+
+	switch o.DefaultRepositoryPermission {
+	case "write", "admin":
+		findings = append(findings, newFinding(ruleOrgDefaultPermission, o.Organization, "default_repository_permission",
+			fmt.Sprintf("every member has %s access to every repository by default", o.DefaultRepositoryPermission)))
+	}
+
+	if o.MembersCanCreatePublicRepositories != nil && *o.MembersCanCreatePublicRepositories {
+		findings = append(findings, newFinding(ruleOrgMembersCreatePublic, o.Organization, "members_can_create_public_repositories",
+			"members can create public repositories"))
+	}
+	if o.MembersCanForkPrivateRepositories != nil && *o.MembersCanForkPrivateRepositories {
+		findings = append(findings, newFinding(ruleOrgMembersForkPrivate, o.Organization, "members_can_fork_private_repositories",
+			"members can fork private repositories"))
+	}
+
+	// The hooks of an organization are sent the events of its private
+	// repositories too.
+	findings = append(findings, hookFindings(o.Organization, true, o.Hooks)...)
+	findings = append(findings, appFindings(o)...)
+
+	return findings
+}
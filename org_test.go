@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestOrgReportAddError(t *testing.T) {
+	status := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code, Request: &http.Request{}}}
+	}
+
+	report := newOrgReport(orgSettings{Login: "genuinetools"})
+	report.addError("hooks", status(http.StatusNotFound))
+	report.addError("app installations", status(http.StatusForbidden))
+	report.addError("owners", status(http.StatusInternalServerError))
+	report.addError("outside collaborators", errors.New("connection reset"))
+
+	if want := []string{"hooks", "app installations"}; !reflect.DeepEqual(report.NotVisible, want) {
+		t.Errorf("not visible = %v, want %v", report.NotVisible, want)
+	}
+	if len(report.Errors) != 2 {
+		t.Errorf("errors = %v, want the owners and outside collaborators ones", report.Errors)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// repoConnection describes a connection on a repository that can be paged
+// through on its own.
+type repoConnection struct {
+	name  string
+	query string
+	// page returns the page info of the connection on the repository.
+	page func(r *ghrepo) *pageInfo
+	// merge appends the nodes of the connection on the page to the repository.
+	merge func(r *ghrepo, page ghrepo)
+}
+
+// repoConnections are the connections fetched with the repository that may
+// have more than one page.
+var repoConnections = []repoConnection{
+	{
+		name:  "refs",
+		query: queryGetRepoRefs,
+		page:  func(r *ghrepo) *pageInfo { return &r.Refs.PageInfo },
+		merge: func(r *ghrepo, page ghrepo) {
+			r.Refs.Nodes = append(r.Refs.Nodes, page.Refs.Nodes...)
+		},
+	},
+	{
+		name:  "branchProtectionRules",
+		query: queryGetRepoProtectionRules,
+		page:  func(r *ghrepo) *pageInfo { return &r.BranchProtectionRules.PageInfo },
+		merge: func(r *ghrepo, page ghrepo) {
+			r.BranchProtectionRules.Nodes = append(r.BranchProtectionRules.Nodes, page.BranchProtectionRules.Nodes...)
+		},
+	},
+	{
+		name:  "deployKeys",
+		query: queryGetRepoDeployKeys,
+		page:  func(r *ghrepo) *pageInfo { return &r.DeployKeys.PageInfo },
+		merge: func(r *ghrepo, page ghrepo) {
+			r.DeployKeys.Nodes = append(r.DeployKeys.Nodes, page.DeployKeys.Nodes...)
+		},
+	},
+	{
+		name:  "collaborators",
+		query: queryGetRepoCollaborators,
+		page:  func(r *ghrepo) *pageInfo { return &r.Collaborators.PageInfo },
+		merge: func(r *ghrepo, page ghrepo) {
+			r.Collaborators.Edges = append(r.Collaborators.Edges, page.Collaborators.Edges...)
+		},
+	},
+}
+
+// paginateRepo follows the cursors of every nested connection on the
+// repository that has more pages, so the repository holds every node and not
+// only the first page.
+func paginateRepo(graphqlClient *GQLClient, repo *ghrepo) error {
+	for _, c := range repoConnections {
+		for c.page(repo).HasNextPage {
+			logrus.Debugf("Executing GraphQL query to fetch the next page of %s for %s", c.name, repo.NameWithOwner)
+			var data repoResponse
+			if err := graphqlClient.Execute(GQLRequest{
+				Query: c.query,
+				Variables: map[string]interface{}{
+					"owner":  repo.Owner.Login,
+					"name":   repo.Name,
+					"cursor": c.page(repo).EndCursor,
+				},
+			}, &data); err != nil {
+				return err
+			}
+
+			c.merge(repo, data.Repository)
+			*c.page(repo) = *c.page(&data.Repository)
+		}
+	}
+
+	for i := range repo.BranchProtectionRules.Nodes {
+		if err := fetchPushAllowances(graphqlClient, repo, &repo.BranchProtectionRules.Nodes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchPushAllowances fetches every push allowance of a rule that restricts
+// pushes. They are not part of the repositories query since nesting them
+// under repositories and rules multiplies its node cost.
+func fetchPushAllowances(graphqlClient *GQLClient, repo *ghrepo, rule *branchProtectionRule) error {
+	if !rule.RestrictsPushes || rule.ID == "" {
+		return nil
+	}
+
+	var cursor interface{}
+	for {
+		logrus.Debugf("Executing GraphQL query to fetch the push allowances of %s on %s", rule.Pattern, repo.NameWithOwner)
+		var data pushAllowancesResponse
+		if err := graphqlClient.Execute(GQLRequest{
+			Query: queryGetPushAllowances,
+			Variables: map[string]interface{}{
+				"id":     rule.ID,
+				"cursor": cursor,
+			},
+		}, &data); err != nil {
+			return fmt.Errorf("fetching the push allowances of protection rule %s: %v", rule.Pattern, err)
+		}
+
+		page := data.Node.PushAllowances
+		rule.PushAllowances.Nodes = append(rule.PushAllowances.Nodes, page.Nodes...)
+		if !page.PageInfo.HasNextPage {
+			return nil
+		}
+		cursor = page.PageInfo.EndCursor
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubTransport answers GraphQL requests with the response returned by fn for
+// the decoded request.
+type stubTransport func(r GQLRequest) string
+
+func (fn stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var r GQLRequest
+	if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(fn(r))),
+		Request:    req,
+	}, nil
+}
+
+func TestPaginateRepo(t *testing.T) {
+	var requests int
+	client := NewGQLClient("https://api.github.com/graphql", &http.Client{Transport: stubTransport(func(r GQLRequest) string {
+		requests++
+		switch {
+		case strings.Contains(r.Query, "query getPushAllowances"):
+			if r.Variables["cursor"] == nil {
+				return `{"data": {"node": {"pushAllowances": {"pageInfo": {"endCursor": "a1", "hasNextPage": true}, "nodes": [{"actor": {"login": "jess"}}]}}}}`
+			}
+			return `{"data": {"node": {"pushAllowances": {"pageInfo": {"hasNextPage": false}, "nodes": [{"actor": {"slug": "release"}}]}}}}`
+		case strings.Contains(r.Query, "refs(first"):
+			return `{"data": {"repository": {"refs": {"pageInfo": {"endCursor": "r2", "hasNextPage": false}, "nodes": [{"name": "release"}]}}}}`
+		case strings.Contains(r.Query, "deployKeys(first"):
+			return `{"data": {"repository": {"deployKeys": {"pageInfo": {"hasNextPage": false}, "nodes": [{"id": "DK_2", "title": "ci"}]}}}}`
+		case strings.Contains(r.Query, "collaborators(first"):
+			return `{"data": {"repository": {"collaborators": {"pageInfo": {"hasNextPage": false}, "edges": [{"permission": "WRITE", "node": {"login": "sam"}}]}}}}`
+		}
+		t.Errorf("unexpected query: %s", r.Query)
+		return `{"data": null}`
+	})}, nil)
+
+	repo := ghrepo{Name: "audit", NameWithOwner: "genuinetools/audit"}
+	repo.Owner.Login = "genuinetools"
+	repo.Refs.Nodes = []nodeElement{{Name: "master"}}
+	repo.Refs.PageInfo = pageInfo{EndCursor: "r1", HasNextPage: true}
+	repo.BranchProtectionRules.Nodes = []branchProtectionRule{
+		{ID: "BPR_1", Pattern: "master", RestrictsPushes: true},
+		{ID: "BPR_2", Pattern: "release"},
+	}
+	repo.DeployKeys.Nodes = []nodeElement{{ID: "DK_1", Title: "deploy"}}
+	repo.DeployKeys.PageInfo = pageInfo{EndCursor: "k1", HasNextPage: true}
+	repo.Collaborators.Edges = []collaboratorEdge{{Permission: "ADMIN"}}
+	repo.Collaborators.Edges[0].Node.Login = "jess"
+	repo.Collaborators.PageInfo = pageInfo{EndCursor: "c1", HasNextPage: true}
+
+	if err := paginateRepo(client, &repo); err != nil {
+		t.Fatal(err)
+	}
+
+	// One request for each connection and two for the push allowances of the
+	// rule restricting pushes.
+	if requests != 5 {
+		t.Errorf("requests = %d, want 5", requests)
+	}
+
+	var refs, keys, collaborators, allowances []string
+	for _, n := range repo.Refs.Nodes {
+		refs = append(refs, n.Name)
+	}
+	for _, n := range repo.DeployKeys.Nodes {
+		keys = append(keys, n.ID)
+	}
+	for _, e := range repo.Collaborators.Edges {
+		collaborators = append(collaborators, e.Node.Login)
+	}
+	for _, a := range repo.BranchProtectionRules.Nodes[0].PushAllowances.Nodes {
+		allowances = append(allowances, a.Actor.name())
+	}
+
+	for _, tc := range []struct {
+		name      string
+		got, want []string
+	}{
+		{"refs", refs, []string{"master", "release"}},
+		{"deploy keys", keys, []string{"DK_1", "DK_2"}},
+		{"collaborators", collaborators, []string{"jess", "sam"}},
+		{"push allowances", allowances, []string{"jess", "release"}},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%s = %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+	if repo.Refs.PageInfo.HasNextPage || repo.DeployKeys.PageInfo.HasNextPage || repo.Collaborators.PageInfo.HasNextPage {
+		t.Error("a connection still reports a next page")
+	}
+	if n := len(repo.BranchProtectionRules.Nodes[1].PushAllowances.Nodes); n != 0 {
+		t.Errorf("rule not restricting pushes has %d push allowances, want 0", n)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/google/go-github/github"
+)
+
+const (
+	sourceDirect       = "direct"
+	sourceTeam         = "team"
+	sourceOrganization = "organization"
+)
+
+// permissionSource is one of the grants a collaborator's access comes from.
+type permissionSource struct {
+	// Type is one of direct, team or organization.
+	Type string `json:"type"`
+	// Name is the name of the team or organization.
+	Name string `json:"name,omitempty"`
+	// Via is the child team the collaborator is a member of, when they
+	// inherit the access of the team from it.
+	Via        string `json:"via,omitempty"`
+	Permission string `json:"permission"`
+}
+
+func (s permissionSource) String() string {
+	switch s.Type {
+	case sourceTeam:
+		if s.Via != "" {
+			return s.Name + " via " + s.Via + " [" + s.Permission + "]"
+		}
+		return s.Name + " [" + s.Permission + "]"
+	case sourceOrganization:
+		return "org " + s.Name + " [" + s.Permission + "]"
+	}
+	return "direct [" + s.Permission + "]"
+}
+
+// directPermission returns the permission granted directly to the
+// collaborator, if any.
+func (c collaboratorReport) directPermission() string {
+	for _, s := range c.Sources {
+		if s.Type == sourceDirect {
+			return s.Permission
+		}
+	}
+	return ""
+}
+
+// permissionRank returns the position of a GraphQL repository permission on
+// the scale, higher grants more.
+func permissionRank(permission string) int {
+	switch permission {
+	case "READ":
+		return 1
+	case "TRIAGE":
+		return 2
+	case "WRITE":
+		return 3
+	case "MAINTAIN":
+		return 4
+	case "ADMIN":
+		return 5
+	}
+	return 0
+}
+
+// explainPermission builds the report of a collaborator, explaining their
+// effective permission with every source it comes from: the direct grant, the
+// organization base permission and the teams with access to the repository,
+// from the GraphQL permission sources. teams holds the teams with access to
+// the repository by slug, to name them, and via the child team the
+// collaborator is a member of for the teams they inherit access from.
+func explainPermission(c collaboratorEdge, teams map[string]*github.Team, via map[string]string) collaboratorReport {
+	report := collaboratorReport{
+		Login:      c.Node.Login,
+		Permission: c.Permission,
+		Teams:      []string{},
+		Sources:    []permissionSource{},
+	}
+
+	var direct, best permissionSource
+	hasTeams := false
+	for _, s := range c.PermissionSources {
+		switch s.Source.Typename {
+		case "Repository":
+			direct = permissionSource{
+				Type:       sourceDirect,
+				Permission: s.Permission,
+			}
+			report.Sources = append(report.Sources, direct)
+		case "Organization":
+			org := permissionSource{
+				Type:       sourceOrganization,
+				Name:       s.Source.Login,
+				Permission: s.Permission,
+			}
+			report.Sources = append(report.Sources, org)
+			if permissionRank(org.Permission) > permissionRank(best.Permission) {
+				best = org
+			}
+		case "Team":
+			team := permissionSource{
+				Type:       sourceTeam,
+				Name:       s.Source.Slug,
+				Via:        via[s.Source.Slug],
+				Permission: s.Permission,
+			}
+			if t, ok := teams[s.Source.Slug]; ok {
+				team.Name = t.GetName()
+			}
+			hasTeams = true
+			report.Teams = append(report.Teams, team.Name)
+			report.Sources = append(report.Sources, team)
+			if permissionRank(team.Permission) > permissionRank(best.Permission) {
+				best = team
+			}
+		}
+	}
+
+	if direct.Type != "" && best.Type != "" {
+		if permissionRank(direct.Permission) <= permissionRank(best.Permission) {
+			report.RedundantDirectGrant = true
+		} else if hasTeams {
+			report.DirectGrantExceedsTeams = true
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestExplainPermission(t *testing.T) {
+	source := func(typename, name, permission string) collaboratorPermission {
+		return collaboratorPermission{
+			Permission: permission,
+			Source: permissionGranter{
+				Typename:      typename,
+				Login:         name,
+				NameWithOwner: name,
+				Slug:          name,
+			},
+		}
+	}
+	teams := teamsBySlug([]*github.Team{
+		{Slug: github.String("platform"), Name: github.String("Platform")},
+	})
+
+	tests := []struct {
+		name        string
+		sources     []collaboratorPermission
+		via         map[string]string
+		want        []string
+		redundant   bool
+		exceedTeams bool
+	}{
+		{
+			name:    "inherited from a parent team",
+			sources: []collaboratorPermission{source("Team", "platform", "WRITE")},
+			via:     map[string]string{"platform": "Platform SRE"},
+			want:    []string{"Platform via Platform SRE [WRITE]"},
+		},
+		{
+			name:    "team unknown to the REST API",
+			sources: []collaboratorPermission{source("Team", "infra", "READ")},
+			want:    []string{"infra [READ]"},
+		},
+		{
+			name:      "direct grant covered by the organization",
+			sources:   []collaboratorPermission{source("Repository", "o/r", "READ"), source("Organization", "o", "WRITE")},
+			want:      []string{"direct [READ]", "org o [WRITE]"},
+			redundant: true,
+		},
+		{
+			name:        "direct grant above the team",
+			sources:     []collaboratorPermission{source("Repository", "o/r", "ADMIN"), source("Team", "platform", "WRITE")},
+			want:        []string{"direct [ADMIN]", "Platform [WRITE]"},
+			exceedTeams: true,
+		},
+	}
+
+	for _, tt := range tests {
+		edge := collaboratorEdge{Permission: "WRITE", PermissionSources: tt.sources}
+		edge.Node.Login = "jess"
+		r := explainPermission(edge, teams, tt.via)
+
+		got := []string{}
+		for _, s := range r.Sources {
+			got = append(got, s.String())
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: sources = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: sources = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+		if r.RedundantDirectGrant != tt.redundant {
+			t.Errorf("%s: redundant direct grant = %t, want %t", tt.name, r.RedundantDirectGrant, tt.redundant)
+		}
+		if r.DirectGrantExceedsTeams != tt.exceedTeams {
+			t.Errorf("%s: direct grant exceeds teams = %t, want %t", tt.name, r.DirectGrantExceedsTeams, tt.exceedTeams)
+		}
+	}
+}
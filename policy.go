@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// policy is a set of rules every audited repository is expected to follow.
+//
+// A policy file looks like:
+//
+//	rules:
+//	  - id: default-branch-reviews
+//	    description: Default branch must be protected with at least 2 reviews
+//	    severity: high
+//	    defaultBranchProtected: true
+//	    minApprovingReviews: 2
+//	  - id: no-write-deploy-keys
+//	    severity: high
+//	    noWriteDeployKeys: true
+//	  - id: squash-only
+//	    severity: medium
+//	    allowedMergeMethods: [squash]
+//	  - id: few-admins
+//	    severity: medium
+//	    maxAdmins: 3
+//	  - id: vetted-actions
+//	    severity: medium
+//	    allowedActions: [local_only, selected]
+type policy struct {
+	Rules []policyRule `yaml:"rules"`
+}
+
+// policyRule is a single rule of a policy. Every condition that is set must
+// hold for a repository to pass the rule.
+type policyRule struct {
+	ID          string   `yaml:"id"`
+	Description string   `yaml:"description"`
+	Severity    severity `yaml:"severity"`
+
+	// DefaultBranchProtected requires a protection rule to cover the default
+	// branch.
+	DefaultBranchProtected bool `yaml:"defaultBranchProtected"`
+	// MinApprovingReviews is the minimum number of approving reviews the
+	// protection rule of the default branch must require.
+	MinApprovingReviews int `yaml:"minApprovingReviews"`
+	// RequireCodeOwnerReviews requires the protection rule of the default
+	// branch to require reviews from code owners.
+	RequireCodeOwnerReviews bool `yaml:"requireCodeOwnerReviews"`
+	// RequireStatusChecks requires the protection rule of the default branch
+	// to require at least one status check.
+	RequireStatusChecks bool `yaml:"requireStatusChecks"`
+	// EnforceForAdmins requires the protection rule of the default branch to
+	// apply to administrators.
+	EnforceForAdmins bool `yaml:"enforceForAdmins"`
+	// NoForcePushes forbids protection rules allowing force pushes.
+	NoForcePushes bool `yaml:"noForcePushes"`
+	// NoWriteDeployKeys forbids deploy keys with write access.
+	NoWriteDeployKeys bool `yaml:"noWriteDeployKeys"`
+	// MaxDeployKeys is the maximum number of deploy keys.
+	MaxDeployKeys *int `yaml:"maxDeployKeys"`
+	// AllowedMergeMethods lists the merge methods that may be enabled, out of
+	// mergeCommit, squash and rebase.
+	AllowedMergeMethods []string `yaml:"allowedMergeMethods"`
+	// MaxAdmins is the maximum number of collaborators with admin access.
+	MaxAdmins *int `yaml:"maxAdmins"`
+	// MaxCollaborators is the maximum number of collaborators.
+	MaxCollaborators *int `yaml:"maxCollaborators"`
+	// AllowedActions lists the Actions policies that may be set, out of all,
+	// local_only and selected.
+	AllowedActions []string `yaml:"allowedActions"`
+}
+
+// policyResult is the outcome of a policy rule on a repository. The reasons
+// a rule failed are in the findings of the repository.
+type policyResult struct {
+	Rule     string   `json:"rule"`
+	Severity severity `json:"severity"`
+	Passed   bool     `json:"passed"`
+}
+
+// loadPolicy reads and validates the policy file at path.
+func loadPolicy(path string) (*policy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file failed: %v", err)
+	}
+
+	var p policy
+	if err := yaml.UnmarshalStrict(b, &p); err != nil {
+		return nil, fmt.Errorf("parsing policy file %s failed: %v", path, err)
+	}
+
+	ids := map[string]bool{}
+	for i, r := range p.Rules {
+		if r.ID == "" {
+			return nil, fmt.Errorf("rule %d in policy file %s has no id", i+1, path)
+		}
+		if ids[r.ID] {
+			return nil, fmt.Errorf("rule %s is defined more than once in policy file %s", r.ID, path)
+		}
+		if _, ok := auditRules[r.ID]; ok {
+			return nil, fmt.Errorf("rule %s in policy file %s has the same id as a built-in rule", r.ID, path)
+		}
+		ids[r.ID] = true
+
+		if r.Severity.rank() < 1 {
+			return nil, fmt.Errorf("rule %s in policy file %s has invalid severity %q (must be one of: low, medium, high, critical)", r.ID, path, r.Severity)
+		}
+		for _, m := range r.AllowedMergeMethods {
+			if m != "mergeCommit" && m != "squash" && m != "rebase" {
+				return nil, fmt.Errorf("rule %s in policy file %s has invalid merge method %q (must be one of: mergeCommit, squash, rebase)", r.ID, path, m)
+			}
+		}
+		for _, a := range r.AllowedActions {
+			if a != actionsAll && a != actionsLocalOnly && a != actionsSelected {
+				return nil, fmt.Errorf("rule %s in policy file %s has invalid Actions policy %q (must be one of: %s, %s, %s)", r.ID, path, a, actionsAll, actionsLocalOnly, actionsSelected)
+			}
+		}
+	}
+
+	return &p, nil
+}
+
+// rules returns the rules of the policy in the same form as the built-in
+// rules.
+func (p *policy) rules() []rule {
+	rules := []rule{}
+	for _, r := range p.Rules {
+		description := r.Description
+		if description == "" {
+			description = r.ID
+		}
+		rules = append(rules, rule{
+			ID:          r.ID,
+			Description: description,
+			Severity:    r.Severity,
+		})
+	}
+	return rules
+}
+
+// evaluate runs every rule of the policy against the repository report and
+// returns the result of each rule along with the findings for the failed
+// ones.
+func (p *policy) evaluate(r *repoReport) ([]policyResult, []finding) {
+	results := []policyResult{}
+	findings := []finding{}
+	for _, pr := range p.Rules {
+		violations := pr.violations(r)
+		results = append(results, policyResult{
+			Rule:     pr.ID,
+			Severity: pr.Severity,
+			Passed:   len(violations) < 1,
+		})
+		for _, v := range violations {
+			findings = append(findings, finding{
+				RuleID:     pr.ID,
+				Severity:   pr.Severity,
+				Repository: r.Repository,
+				Identifier: v.identifier,
+				Message:    v.message,
+			})
+		}
+	}
+	return results, findings
+}
+
+// violation is a reason a repository fails a policy rule.
+type violation struct {
+	identifier string
+	message    string
+}
+
+// violations returns every reason the repository fails the rule.
+func (pr policyRule) violations(r *repoReport) []violation {
+	var v []violation
+
+	// Empty repositories have no default branch to check.
+	if r.DefaultBranch != "" {
+		protection, protected := effectiveRule(r.ProtectionRules, r.DefaultBranch)
+		if pr.DefaultBranchProtected && !protected {
+			v = append(v, violation{r.DefaultBranch, fmt.Sprintf("default branch %s is not protected", r.DefaultBranch)})
+		}
+		if pr.MinApprovingReviews > 0 && protection.RequiredApprovingReviewCount < pr.MinApprovingReviews {
+			v = append(v, violation{r.DefaultBranch, fmt.Sprintf("default branch %s requires %d approving reviews, at least %d are required",
+				r.DefaultBranch, protection.RequiredApprovingReviewCount, pr.MinApprovingReviews)})
+		}
+		if pr.RequireCodeOwnerReviews && !protection.RequiresCodeOwnerReviews {
+			v = append(v, violation{r.DefaultBranch, fmt.Sprintf("default branch %s does not require code owner reviews", r.DefaultBranch)})
+		}
+		if pr.RequireStatusChecks && len(protection.RequiredStatusCheckContexts) < 1 {
+			v = append(v, violation{r.DefaultBranch, fmt.Sprintf("default branch %s does not require status checks", r.DefaultBranch)})
+		}
+		if pr.EnforceForAdmins && !protection.EnforceForAdmins {
+			v = append(v, violation{r.DefaultBranch, fmt.Sprintf("protection of default branch %s is not enforced for administrators", r.DefaultBranch)})
+		}
+	}
+
+	if pr.NoForcePushes {
+		for _, p := range r.ProtectionRules {
+			if p.AllowsForcePushes {
+				v = append(v, violation{p.Pattern, fmt.Sprintf("protection rule %s allows force pushes", p.Pattern)})
+			}
+		}
+	}
+
+	if pr.NoWriteDeployKeys {
+		for _, k := range r.DeployKeys {
+			if !k.ReadOnly {
+				v = append(v, violation{k.ID, fmt.Sprintf("deploy key %q has write access", k.Title)})
+			}
+		}
+	}
+	if pr.MaxDeployKeys != nil && len(r.DeployKeys) > *pr.MaxDeployKeys {
+		v = append(v, violation{"deploy-keys", fmt.Sprintf("%d deploy keys, at most %d are allowed", len(r.DeployKeys), *pr.MaxDeployKeys)})
+	}
+
+	if pr.AllowedMergeMethods != nil {
+		for _, m := range r.MergeMethods {
+			if !contains(pr.AllowedMergeMethods, m) {
+				v = append(v, violation{m, fmt.Sprintf("merge method %s is enabled, allowed merge methods: %s", m, strings.Join(pr.AllowedMergeMethods, ", "))})
+			}
+		}
+	}
+
+	if pr.MaxAdmins != nil {
+		admins := []string{}
+		for _, c := range r.Collaborators {
+			if c.Permission == "ADMIN" {
+				admins = append(admins, c.Login)
+			}
+		}
+		if len(admins) > *pr.MaxAdmins {
+			v = append(v, violation{"admins", fmt.Sprintf("%d admins (%s), at most %d are allowed", len(admins), strings.Join(admins, ", "), *pr.MaxAdmins)})
+		}
+	}
+	if pr.MaxCollaborators != nil && len(r.Collaborators) > *pr.MaxCollaborators {
+		v = append(v, violation{"collaborators", fmt.Sprintf("%d collaborators, at most %d are allowed", len(r.Collaborators), *pr.MaxCollaborators)})
+	}
+
+	if pr.AllowedActions != nil && r.Actions != nil && r.Actions.Enabled && !contains(pr.AllowedActions, r.Actions.AllowedActions) {
+		v = append(v, violation{"allowed_actions", fmt.Sprintf("Actions policy %s is set, allowed Actions policies: %s", r.Actions.AllowedActions, strings.Join(pr.AllowedActions, ", "))})
+	}
+
+	return v
+}
+
+// contains reports whether the slice holds the string.
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
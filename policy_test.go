@@ -0,0 +1,277 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicyRuleViolations(t *testing.T) {
+	one, three := 1, 3
+
+	report := &repoReport{
+		Repository:    "genuinetools/audit",
+		DefaultBranch: "master",
+		ProtectionRules: []protectionRuleReport{
+			{Pattern: "master", RequiredApprovingReviewCount: 1, RequiredStatusCheckContexts: []string{"ci"}},
+			{Pattern: "release/*", AllowsForcePushes: true},
+		},
+		DeployKeys: []deployKeyReport{
+			{ID: "DK_1", Title: "deploy", ReadOnly: true},
+			{ID: "DK_2", Title: "ci", ReadOnly: false},
+		},
+		MergeMethods: []string{"mergeCommit", "squash"},
+		Collaborators: []collaboratorReport{
+			{Login: "jess", Permission: "ADMIN"},
+			{Login: "sam", Permission: "ADMIN"},
+			{Login: "kim", Permission: "WRITE"},
+		},
+		Actions: &actionsReport{Enabled: true, AllowedActions: actionsAll},
+	}
+	unprotected := &repoReport{Repository: "genuinetools/img", DefaultBranch: "main"}
+
+	for _, tc := range []struct {
+		name   string
+		rule   policyRule
+		report *repoReport
+		want   []violation
+	}{
+		{
+			name:   "no conditions",
+			rule:   policyRule{ID: "empty"},
+			report: report,
+		},
+		{
+			name:   "default branch protected",
+			rule:   policyRule{DefaultBranchProtected: true},
+			report: report,
+		},
+		{
+			name:   "default branch unprotected",
+			rule:   policyRule{DefaultBranchProtected: true},
+			report: unprotected,
+			want:   []violation{{"main", "default branch main is not protected"}},
+		},
+		{
+			name:   "empty repository",
+			rule:   policyRule{DefaultBranchProtected: true, MinApprovingReviews: 2},
+			report: &repoReport{Repository: "genuinetools/empty"},
+		},
+		{
+			name:   "min approving reviews",
+			rule:   policyRule{MinApprovingReviews: 2},
+			report: report,
+			want:   []violation{{"master", "default branch master requires 1 approving reviews, at least 2 are required"}},
+		},
+		{
+			name:   "min approving reviews met",
+			rule:   policyRule{MinApprovingReviews: 1},
+			report: report,
+		},
+		{
+			name:   "code owner reviews",
+			rule:   policyRule{RequireCodeOwnerReviews: true},
+			report: report,
+			want:   []violation{{"master", "default branch master does not require code owner reviews"}},
+		},
+		{
+			name:   "status checks",
+			rule:   policyRule{RequireStatusChecks: true},
+			report: unprotected,
+			want:   []violation{{"main", "default branch main does not require status checks"}},
+		},
+		{
+			name:   "enforce for admins",
+			rule:   policyRule{EnforceForAdmins: true},
+			report: report,
+			want:   []violation{{"master", "protection of default branch master is not enforced for administrators"}},
+		},
+		{
+			name:   "force pushes",
+			rule:   policyRule{NoForcePushes: true},
+			report: report,
+			want:   []violation{{"release/*", "protection rule release/* allows force pushes"}},
+		},
+		{
+			name:   "write deploy keys",
+			rule:   policyRule{NoWriteDeployKeys: true},
+			report: report,
+			want:   []violation{{"DK_2", `deploy key "ci" has write access`}},
+		},
+		{
+			name:   "max deploy keys",
+			rule:   policyRule{MaxDeployKeys: &one},
+			report: report,
+			want:   []violation{{"deploy-keys", "2 deploy keys, at most 1 are allowed"}},
+		},
+		{
+			name:   "allowed merge methods",
+			rule:   policyRule{AllowedMergeMethods: []string{"squash"}},
+			report: report,
+			want:   []violation{{"mergeCommit", "merge method mergeCommit is enabled, allowed merge methods: squash"}},
+		},
+		{
+			name:   "max admins",
+			rule:   policyRule{MaxAdmins: &one},
+			report: report,
+			want:   []violation{{"admins", "2 admins (jess, sam), at most 1 are allowed"}},
+		},
+		{
+			name:   "max collaborators",
+			rule:   policyRule{MaxCollaborators: &three},
+			report: report,
+		},
+		{
+			name:   "max collaborators exceeded",
+			rule:   policyRule{MaxCollaborators: &one},
+			report: report,
+			want:   []violation{{"collaborators", "3 collaborators, at most 1 are allowed"}},
+		},
+		{
+			name:   "allowed actions",
+			rule:   policyRule{AllowedActions: []string{actionsLocalOnly, actionsSelected}},
+			report: report,
+			want:   []violation{{"allowed_actions", "Actions policy all is set, allowed Actions policies: local_only, selected"}},
+		},
+		{
+			name:   "allowed actions unknown",
+			rule:   policyRule{AllowedActions: []string{actionsLocalOnly}},
+			report: unprotected,
+		},
+	} {
+		got := tc.rule.violations(tc.report)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: violations = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	p := &policy{Rules: []policyRule{
+		{ID: "protected", Severity: severityHigh, DefaultBranchProtected: true},
+		{ID: "squash-only", Severity: severityLow, AllowedMergeMethods: []string{"squash"}},
+	}}
+	results, findings := p.evaluate(&repoReport{
+		Repository:      "genuinetools/audit",
+		DefaultBranch:   "master",
+		ProtectionRules: []protectionRuleReport{{Pattern: "master"}},
+		MergeMethods:    []string{"squash", "rebase"},
+	})
+
+	wantResults := []policyResult{
+		{Rule: "protected", Severity: severityHigh, Passed: true},
+		{Rule: "squash-only", Severity: severityLow, Passed: false},
+	}
+	if !reflect.DeepEqual(results, wantResults) {
+		t.Errorf("results = %v, want %v", results, wantResults)
+	}
+	if len(findings) != 1 || findings[0].RuleID != "squash-only" || findings[0].Identifier != "rebase" || findings[0].Severity != severityLow {
+		t.Errorf("findings = %+v, want one squash-only finding for rebase", findings)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name   string
+		policy string
+		// err is a substring of the expected error, empty when the policy is
+		// valid.
+		err string
+	}{
+		{
+			name: "valid",
+			policy: `rules:
+  - id: reviews
+    severity: high
+    defaultBranchProtected: true
+    minApprovingReviews: 2
+  - id: admins
+    severity: medium
+    maxAdmins: 0
+    allowedMergeMethods: [squash, rebase]
+    allowedActions: [local_only]
+`,
+		},
+		{
+			name:   "bad yaml",
+			policy: "rules:\n  - id: [reviews\n",
+			err:    "parsing policy file",
+		},
+		{
+			name:   "unknown key",
+			policy: "rules:\n  - id: reviews\n    severity: high\n    minReviews: 2\n",
+			err:    "field minReviews not found",
+		},
+		{
+			name:   "wrong type",
+			policy: "rules:\n  - id: reviews\n    severity: high\n    minApprovingReviews: two\n",
+			err:    "parsing policy file",
+		},
+		{
+			name:   "missing id",
+			policy: "rules:\n  - severity: high\n",
+			err:    "rule 1 in policy file",
+		},
+		{
+			name:   "duplicate id",
+			policy: "rules:\n  - id: reviews\n    severity: high\n  - id: reviews\n    severity: low\n",
+			err:    "rule reviews is defined more than once",
+		},
+		{
+			name:   "built-in id",
+			policy: "rules:\n  - id: " + ruleDefaultBranchUnprotected + "\n    severity: high\n",
+			err:    "same id as a built-in rule",
+		},
+		{
+			name:   "missing severity",
+			policy: "rules:\n  - id: reviews\n",
+			err:    `invalid severity ""`,
+		},
+		{
+			name:   "invalid severity",
+			policy: "rules:\n  - id: reviews\n    severity: urgent\n",
+			err:    `invalid severity "urgent"`,
+		},
+		{
+			name:   "invalid merge method",
+			policy: "rules:\n  - id: reviews\n    severity: low\n    allowedMergeMethods: [merge]\n",
+			err:    `invalid merge method "merge"`,
+		},
+		{
+			name:   "invalid actions policy",
+			policy: "rules:\n  - id: reviews\n    severity: low\n    allowedActions: [none]\n",
+			err:    `invalid Actions policy "none"`,
+		},
+	} {
+		path := filepath.Join(dir, strings.Replace(tc.name, " ", "-", -1)+".yml")
+		if err := ioutil.WriteFile(path, []byte(tc.policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := loadPolicy(path)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			} else if len(p.Rules) != 2 || *p.Rules[1].MaxAdmins != 0 {
+				t.Errorf("%s: rules = %+v", tc.name, p.Rules)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: error = %v, want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+
+	if _, err := loadPolicy(filepath.Join(dir, "missing.yml")); err == nil {
+		t.Error("loading a missing policy file did not fail")
+	}
+}
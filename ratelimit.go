@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxRetries is the number of times a request is retried after a transient
+	// failure or after waiting out a rate limit.
+	maxRetries = 5
+	// maxBackoff caps the exponential backoff between retries.
+	maxBackoff = 30 * time.Second
+	// secondaryRateLimitWait is how long to wait on a secondary rate limit
+	// that did not say when to retry.
+	secondaryRateLimitWait = time.Minute
+)
+
+// rateLimitTransport is an http.RoundTripper that waits out GitHub rate limits
+// and retries transient failures with backoff.
+//
+// A single transport is meant to be shared by every client talking to GitHub
+// so they all respect the same budget: once a response says a rate limit is
+// exhausted, every request for the same resource waits until it resets.
+type rateLimitTransport struct {
+	base http.RoundTripper
+
+	mu sync.Mutex
+	// pausedUntil holds, per rate limit resource, when requests may resume.
+	pausedUntil map[string]time.Time
+}
+
+// newRateLimitTransport returns a rateLimitTransport sending requests through
+// base.
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:        base,
+		pausedUntil: map[string]time.Time{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req)
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req.Context(), resource); err != nil {
+			return nil, err
+		}
+
+		r, err := rewindRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			if attempt >= maxRetries || req.Context().Err() != nil {
+				return nil, err
+			}
+			delay := backoff(attempt)
+			logrus.WithError(err).Debugf("%s %s failed, retrying in %s", req.Method, req.URL.Path, delay)
+			if err := sleep(req.Context(), delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		logRateLimit(req, resp)
+
+		wait, limited, err := rateLimited(resp)
+		if err != nil {
+			return nil, err
+		}
+		if limited {
+			if attempt >= maxRetries {
+				return resp, nil
+			}
+			resp.Body.Close()
+			logrus.Infof("GitHub %s rate limit hit, waiting %s before retrying", resource, wait.Round(time.Second))
+			t.pause(resource, wait)
+			continue
+		}
+
+		if resp.StatusCode >= 500 && attempt < maxRetries {
+			resp.Body.Close()
+			delay := backoff(attempt)
+			logrus.Debugf("%s %s returned %s, retrying in %s", req.Method, req.URL.Path, resp.Status, delay)
+			if err := sleep(req.Context(), delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Make the following requests wait if the budget is now spent.
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			t.pause(resource, untilReset(resp))
+		}
+
+		return resp, nil
+	}
+}
+
+// isRateLimit reports whether err is a primary or secondary rate limit error
+// that outlasted every retry.
+//
+// The audits record anything they could not fetch in the errors of their
+// report and carry on, except for a rate limit error: they return it as is so
+// the run stops, instead of recording it against every repository left.
+func isRateLimit(err error) bool {
+	_, ok := rateLimitWait(err)
+	return ok
+}
+
+// rateLimitWait returns how long to wait before retrying after err, if it is
+// a primary or secondary rate limit error.
+func rateLimitWait(err error) (time.Duration, bool) {
+	switch err := err.(type) {
+	case *github.RateLimitError:
+		// Add a second to account for clock skew.
+		return time.Until(err.Rate.Reset.Time) + time.Second, true
+	case *github.AbuseRateLimitError:
+		if err.RetryAfter != nil {
+			return *err.RetryAfter, true
+		}
+		return secondaryRateLimitWait, true
+	}
+	return 0, false
+}
+
+// waitRateLimit calls do, a go-github call, and calls it again once the rate
+// limit resets when it fails with a primary or secondary rate limit error.
+//
+// Once a response says the rate limit is spent, go-github fails every
+// following request on its own until the reset, without ever sending it
+// through the transport, so every REST call has to go through here to wait.
+func waitRateLimit(ctx context.Context, do func() (*github.Response, error)) (*github.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := do()
+		wait, ok := rateLimitWait(err)
+		if !ok || attempt >= maxRetries {
+			return resp, err
+		}
+
+		logrus.Infof("GitHub rate limit hit, waiting %s before retrying", wait.Round(time.Second))
+		if err := sleep(ctx, wait); err != nil {
+			return resp, err
+		}
+	}
+}
+
+// wait blocks until requests for the resource may be sent again.
+func (t *rateLimitTransport) wait(ctx context.Context, resource string) error {
+	t.mu.Lock()
+	until := t.pausedUntil[resource]
+	t.mu.Unlock()
+
+	return sleep(ctx, time.Until(until))
+}
+
+// pause makes every request for the resource wait for d.
+func (t *rateLimitTransport) pause(resource string, d time.Duration) {
+	until := time.Now().Add(d)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(t.pausedUntil[resource]) {
+		t.pausedUntil[resource] = until
+	}
+}
+
+// rateLimitResource returns the name GitHub uses for the rate limit the
+// request counts against.
+func rateLimitResource(req *http.Request) string {
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		return "graphql"
+	}
+	return "core"
+}
+
+// rewindRequest returns the request to send for the attempt, with a fresh
+// body for retries.
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := new(http.Request)
+	*r = *req
+	r.Body = body
+	return r, nil
+}
+
+// rateLimited reports whether the response is a primary or secondary rate
+// limit error, and how long to wait before retrying.
+func rateLimited(resp *http.Response) (time.Duration, bool, error) {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if after := resp.Header.Get("Retry-After"); after != "" {
+			if secs, err := strconv.Atoi(after); err == nil {
+				return time.Duration(secs) * time.Second, true, nil
+			}
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return untilReset(resp), true, nil
+		}
+
+		body, err := peekBody(resp)
+		if err != nil {
+			return 0, false, err
+		}
+		msg := strings.ToLower(string(body))
+		if strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse") {
+			return secondaryRateLimitWait, true, nil
+		}
+	case http.StatusOK:
+		// GraphQL reports an exhausted rate limit as an error in a
+		// successful response.
+		if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+			break
+		}
+		body, err := peekBody(resp)
+		if err != nil {
+			return 0, false, err
+		}
+		if bytes.Contains(body, []byte(`"RATE_LIMITED"`)) {
+			return untilReset(resp), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// untilReset returns the time left until the rate limit of the response
+// resets.
+func untilReset(resp *http.Response) time.Duration {
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return secondaryRateLimitWait
+	}
+	// Add a second to account for clock skew.
+	d := time.Until(time.Unix(reset, 0)) + time.Second
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// peekBody reads the body of the response and puts it back so it can be read
+// again.
+func peekBody(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// logRateLimit logs the remaining rate limit budget of the response.
+func logRateLimit(req *http.Request, resp *http.Response) {
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		return
+	}
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = rateLimitResource(req)
+	}
+	logrus.Debugf("GitHub %s rate limit: %s/%s remaining, resets in %s", resource, remaining,
+		resp.Header.Get("X-RateLimit-Limit"), untilReset(resp).Round(time.Second))
+}
+
+// backoff returns the exponential backoff delay for the attempt.
+func backoff(attempt int) time.Duration {
+	d := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// sleep waits for d or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
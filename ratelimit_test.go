@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestRestGetWaitsForRateLimitReset(t *testing.T) {
+	reset := time.Now().Add(time.Second).Unix()
+
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining := "0"
+		if atomic.AddInt32(&hits, 1) > 1 {
+			remaining = "4999"
+		}
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", remaining)
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	// Leave the transport out so only the go-github side is exercised.
+	restClient := github.NewClient(srv.Client())
+	restClient.BaseURL, _ = url.Parse(srv.URL + "/")
+	a := &auditor{restClient: restClient}
+
+	ctx := context.Background()
+	var v struct{}
+	if _, err := a.restGet(ctx, "first", &v); err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := a.restGet(ctx, "second", &v); err != nil {
+		t.Fatalf("second request failed: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("server got %d requests, want 2", got)
+	}
+	if elapsed := time.Since(start); time.Now().Unix() < reset || elapsed < 500*time.Millisecond {
+		t.Errorf("second request was sent after %s, before the rate limit reset", elapsed)
+	}
+}
+
+func TestRateLimitTransportPausesWhenBudgetIsSpent(t *testing.T) {
+	reset := time.Now().Add(time.Second).Unix()
+
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining := "0"
+		if atomic.AddInt32(&hits, 1) > 1 {
+			remaining = "4999"
+		}
+		w.Header().Set("X-RateLimit-Remaining", remaining)
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport)}
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+		if i == 1 && time.Since(start) < 500*time.Millisecond {
+			t.Errorf("second request was sent after %s, before the rate limit reset", time.Since(start))
+		}
+	}
+}
+
+func TestRestGetWaitsForSecondaryRateLimit(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message": "You have exceeded a secondary rate limit.", "documentation_url": "https://developer.github.com/v3/#abuse-rate-limits"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	restClient := github.NewClient(srv.Client())
+	restClient.BaseURL, _ = url.Parse(srv.URL + "/")
+	a := &auditor{restClient: restClient}
+
+	start := time.Now()
+	var v struct{}
+	if _, err := a.restGet(context.Background(), "secrets", &v); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("server got %d requests, want 2", got)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("request was retried after %s, before Retry-After", elapsed)
+	}
+}
+
+func TestIsRateLimit(t *testing.T) {
+	retryAfter := 30 * time.Second
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"primary", &github.RateLimitError{}, true},
+		{"secondary", &github.AbuseRateLimitError{RetryAfter: &retryAfter}, true},
+		{"secondary without retry after", &github.AbuseRateLimitError{}, true},
+		{"not found", &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}, false},
+		{"nil", nil, false},
+	} {
+		if got := isRateLimit(tc.err); got != tc.want {
+			t.Errorf("%s: isRateLimit = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+
+	if wait, _ := rateLimitWait(&github.AbuseRateLimitError{RetryAfter: &retryAfter}); wait != retryAfter {
+		t.Errorf("wait = %s, want the Retry-After of %s", wait, retryAfter)
+	}
+	if wait, _ := rateLimitWait(&github.AbuseRateLimitError{}); wait != secondaryRateLimitWait {
+		t.Errorf("wait = %s, want %s", wait, secondaryRateLimitWait)
+	}
+}
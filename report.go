@@ -0,0 +1,633 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// auditReport is the document emitted for a whole run in json format.
+type auditReport struct {
+	Organizations []*orgReport  `json:"organizations,omitempty"`
+	Repositories  []*repoReport `json:"repositories"`
+}
+
+// repoReport holds everything the audit collected for a single repository.
+type repoReport struct {
+	Repository             string                 `json:"repository"`
+	URL                    string                 `json:"url"`
+	Private                bool                   `json:"private"`
+	DefaultBranch          string                 `json:"defaultBranch,omitempty"`
+	DefaultBranchProtected bool                   `json:"defaultBranchProtected"`
+	Collaborators          []collaboratorReport   `json:"collaborators"`
+	DeployKeys             []deployKeyReport      `json:"deployKeys"`
+	Hooks                  []hookReport           `json:"hooks"`
+	ProtectionRules        []protectionRuleReport `json:"protectionRules"`
+	UnprotectedBranches    []string               `json:"unprotectedBranches"`
+	MergeMethods           []string               `json:"mergeMethods"`
+	// Actions holds the GitHub Actions settings, it is nil when they could
+	// not be fetched.
+	Actions *actionsReport `json:"actions,omitempty"`
+	// Workflows holds the workflows on the default branch.
+	Workflows []workflowReport `json:"workflows"`
+	// Environments holds the deployment environments.
+	Environments []environmentReport `json:"environments"`
+	Findings     []finding           `json:"findings"`
+	// Suppressed holds the findings left out of Findings by the
+	// suppressions file.
+	Suppressed []finding `json:"suppressed,omitempty"`
+	// Policy holds the result of every rule of the policy, if any.
+	Policy []policyResult `json:"policy,omitempty"`
+	// NotVisible holds what the token is not allowed to see on the
+	// repository, like the Actions secrets only its admins can list. Unlike
+	// errors, it does not make the audit incomplete.
+	NotVisible []string `json:"notVisible"`
+	// Errors holds everything that could not be audited on the repository.
+	Errors []string `json:"errors"`
+}
+
+// orgReport holds everything the audit collected on the settings of an
+// organization.
+type orgReport struct {
+	Organization string `json:"organization"`
+	URL          string `json:"url"`
+	// DefaultRepositoryPermission and the settings below are only visible to
+	// the owners of the organization, they are left out otherwise.
+	DefaultRepositoryPermission         string               `json:"defaultRepositoryPermission,omitempty"`
+	MembersCanCreatePublicRepositories  *bool                `json:"membersCanCreatePublicRepositories,omitempty"`
+	MembersCanCreatePrivateRepositories *bool                `json:"membersCanCreatePrivateRepositories,omitempty"`
+	MembersCanForkPrivateRepositories   *bool                `json:"membersCanForkPrivateRepositories,omitempty"`
+	TwoFactorRequirementEnabled         *bool                `json:"twoFactorRequirementEnabled,omitempty"`
+	Owners                              []string             `json:"owners"`
+	MembersWithoutTwoFactor             []string             `json:"membersWithoutTwoFactor"`
+	OutsideCollaborators                int                  `json:"outsideCollaborators"`
+	Hooks                               []hookReport         `json:"hooks"`
+	VerifiedDomains                     []string             `json:"verifiedDomains"`
+	Installations                       []installationReport `json:"installations"`
+	// OAuthAppRestrictions tells whether only approved OAuth Apps can access
+	// the organization, it is only known from the audit log.
+	OAuthAppRestrictions *bool     `json:"oauthAppRestrictions,omitempty"`
+	ApprovedOAuthApps    []string  `json:"approvedOAuthApps"`
+	Findings             []finding `json:"findings"`
+	// Suppressed holds the findings left out of Findings by the
+	// suppressions file.
+	Suppressed []finding `json:"suppressed,omitempty"`
+	// NotVisible holds what the token is not allowed to see on the
+	// organization, like the settings only its owners can see. Unlike
+	// errors, it does not make the audit incomplete.
+	NotVisible []string `json:"notVisible"`
+	// Errors holds everything that could not be audited on the
+	// organization.
+	Errors []string `json:"errors"`
+}
+
+// installationReport is a GitHub App installed on an organization.
+type installationReport struct {
+	ID  int64  `json:"id"`
+	App string `json:"app"`
+	URL string `json:"url"`
+	// RepositorySelection is all or selected. Which repositories are
+	// selected can only be listed with a token of the app itself or of a
+	// user, and only the ones that user can see, so they are not reported.
+	RepositorySelection string            `json:"repositorySelection"`
+	Permissions         map[string]string `json:"permissions"`
+	Events              []string          `json:"events"`
+	CreatedAt           time.Time         `json:"createdAt"`
+	Suspended           bool              `json:"suspended"`
+}
+
+type collaboratorReport struct {
+	Login      string `json:"login"`
+	Permission string `json:"permission"`
+	// Teams holds the names of the teams the collaborator gets access to the
+	// repository from, directly or through a child team.
+	Teams []string `json:"teams"`
+	// Sources explains where the permission comes from.
+	Sources []permissionSource `json:"sources"`
+	// RedundantDirectGrant is set when the collaborator is granted access
+	// directly but their teams or organization already grant as much.
+	RedundantDirectGrant bool `json:"redundantDirectGrant"`
+	// DirectGrantExceedsTeams is set when the direct grant gives the
+	// collaborator more access than their teams do.
+	DirectGrantExceedsTeams bool `json:"directGrantExceedsTeams"`
+	// OutsideCollaborator is set when the collaborator is not a member of
+	// the organization owning the repository. It is only checked by the who
+	// command.
+	OutsideCollaborator bool `json:"outsideCollaborator,omitempty"`
+}
+
+type deployKeyReport struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	ReadOnly bool   `json:"readOnly"`
+	URL      string `json:"url,omitempty"`
+	// Type, Bits and Fingerprint describe the public key, they are empty
+	// when it could not be parsed.
+	Type        string `json:"type,omitempty"`
+	Bits        int    `json:"bits,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Weak is set for DSA keys and RSA keys shorter than 2048 bits.
+	Weak      bool      `json:"weak"`
+	CreatedAt time.Time `json:"createdAt"`
+	AgeDays   int       `json:"ageDays"`
+	Verified  bool      `json:"verified"`
+}
+
+type hookReport struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	URL    string `json:"url"`
+	// Target is the URL payloads are delivered to, with any credentials
+	// redacted. It is empty for legacy services.
+	Target string   `json:"target,omitempty"`
+	Host   string   `json:"host,omitempty"`
+	Events []string `json:"events"`
+	// HasSecret is set when payloads are signed with a secret.
+	HasSecret bool `json:"hasSecret"`
+	// InsecureSSL is set when the certificate of the target is not verified.
+	InsecureSSL bool `json:"insecureSSL"`
+	// InsecureURL is set when the target is a plain http URL.
+	InsecureURL bool `json:"insecureURL"`
+	// CredentialsInURL is set when the target has credentials embedded in it.
+	CredentialsInURL bool `json:"credentialsInURL"`
+	// ApprovedHost tells whether the host is in the allowlist, it is only set
+	// when there is one.
+	ApprovedHost *bool `json:"approvedHost,omitempty"`
+	// Health is one of healthy, failing, never-delivered or unknown, when
+	// the deliveries of the hook could not be listed.
+	Health string `json:"health,omitempty"`
+	// LastStatusCode is the status code of the last delivery, 0 when it
+	// could not connect.
+	LastStatusCode int        `json:"lastStatusCode,omitempty"`
+	LastDelivery   *time.Time `json:"lastDelivery,omitempty"`
+	// FailingSince is when the deliveries started failing, as far back as
+	// the recent deliveries go.
+	FailingSince *time.Time `json:"failingSince,omitempty"`
+}
+
+type actionsReport struct {
+	Enabled bool `json:"enabled"`
+	// AllowedActions is all, local_only or selected.
+	AllowedActions string `json:"allowedActions,omitempty"`
+	// DefaultWorkflowPermissions is the access of the GITHUB_TOKEN, read or
+	// write.
+	DefaultWorkflowPermissions   string `json:"defaultWorkflowPermissions,omitempty"`
+	CanApprovePullRequestReviews bool   `json:"canApprovePullRequestReviews"`
+	// ForkPRApprovalPolicy tells which contributors need approval to run
+	// workflows on pull requests from forks, only for public repositories.
+	ForkPRApprovalPolicy string `json:"forkPullRequestApprovalPolicy,omitempty"`
+	// Secrets holds the names of the secrets, never their values.
+	Secrets []string `json:"secrets"`
+}
+
+type environmentReport struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Production is set when the name marks the environment as production,
+	// see -production-env.
+	Production bool `json:"production"`
+	// RequiredReviewers holds the users and teams who must approve a
+	// deployment.
+	RequiredReviewers []string `json:"requiredReviewers"`
+	// WaitTimer is the number of minutes deployments wait before they start.
+	WaitTimer int `json:"waitTimer"`
+	// BranchPolicy tells which branches can deploy: all, protected or
+	// custom, in which case BranchPatterns holds the allowed patterns.
+	BranchPolicy   string   `json:"branchPolicy"`
+	BranchPatterns []string `json:"branchPatterns,omitempty"`
+	// Secrets holds the names of the secrets, never their values.
+	Secrets []string `json:"secrets"`
+}
+
+type workflowReport struct {
+	Path     string   `json:"path"`
+	Triggers []string `json:"triggers"`
+	// Issues holds the dangerous patterns found in the workflow, each is
+	// reported as a finding too.
+	Issues []workflowIssue `json:"issues"`
+}
+
+type workflowIssue struct {
+	Rule       string `json:"rule"`
+	Identifier string `json:"identifier"`
+	Message    string `json:"message"`
+}
+
+type protectionRuleReport struct {
+	Pattern string `json:"pattern"`
+	// MatchingBranches holds the branches the rule's pattern covers.
+	MatchingBranches             []string `json:"matchingBranches"`
+	RequiredApprovingReviewCount int      `json:"requiredApprovingReviewCount"`
+	DismissesStaleReviews        bool     `json:"dismissesStaleReviews"`
+	RequiresCodeOwnerReviews     bool     `json:"requiresCodeOwnerReviews"`
+	RequiredStatusCheckContexts  []string `json:"requiredStatusCheckContexts"`
+	EnforceForAdmins             bool     `json:"enforceForAdmins"`
+	AllowsForcePushes            bool     `json:"allowsForcePushes"`
+	AllowsDeletions              bool     `json:"allowsDeletions"`
+	RequiresLinearHistory        bool     `json:"requiresLinearHistory"`
+	RequiresSignatures           bool     `json:"requiresSignatures"`
+	RestrictsPushes              bool     `json:"restrictsPushes"`
+	// PushAllowances holds the users, teams and apps that can push when
+	// pushes are restricted.
+	PushAllowances []string `json:"pushAllowances"`
+}
+
+// settings returns the settings of the rule on a single line.
+func (p protectionRuleReport) settings() string {
+	s := fmt.Sprintf("reviews:%d dismissStale:%t codeOwners:%t checks:[%s] admins:%t forcePush:%t deletions:%t linear:%t signatures:%t",
+		p.RequiredApprovingReviewCount, p.DismissesStaleReviews, p.RequiresCodeOwnerReviews,
+		strings.Join(p.RequiredStatusCheckContexts, ", "), p.EnforceForAdmins, p.AllowsForcePushes,
+		p.AllowsDeletions, p.RequiresLinearHistory, p.RequiresSignatures)
+	if p.RestrictsPushes {
+		return s + fmt.Sprintf(" pushRestrictedTo:[%s]", strings.Join(p.PushAllowances, ", "))
+	}
+	return s + " pushRestricted:false"
+}
+
+// text returns the Actions section of the text report.
+func (a *actionsReport) text() string {
+	if !a.Enabled {
+		return "\tActions: enabled:false\n"
+	}
+
+	output := fmt.Sprintf("\tActions: enabled:true allowed:%s token:%s approvePRs:%t", a.AllowedActions, a.DefaultWorkflowPermissions, a.CanApprovePullRequestReviews)
+	if a.ForkPRApprovalPolicy != "" {
+		output += " forkApproval:" + a.ForkPRApprovalPolicy
+	}
+	output += "\n"
+
+	if len(a.Secrets) > 0 {
+		output += fmt.Sprintf("\t\tSecrets (%d): %s\n", len(a.Secrets), strings.Join(a.Secrets, ", "))
+	}
+	return output
+}
+
+// reporter renders repository reports as they are produced.
+type reporter interface {
+	// Report is called once for every audited repository.
+	Report(r *repoReport) error
+	// Close is called once after all repositories have been audited.
+	Close() error
+}
+
+// orgReporter is implemented by the reporters that render organization
+// reports too.
+type orgReporter interface {
+	// ReportOrg is called once for every audited organization, before its
+	// repositories are.
+	ReportOrg(o *orgReport) error
+}
+
+// reportOrg passes the organization report to rep, if it renders them.
+func reportOrg(rep reporter, o *orgReport) error {
+	if orgRep, ok := rep.(orgReporter); ok {
+		return orgRep.ReportOrg(o)
+	}
+	return nil
+}
+
+// newReporter returns the reporter for the given output format.
+func newReporter(format string, w io.Writer) (reporter, error) {
+	switch format {
+	case formatText:
+		return &textReporter{w: w}, nil
+	case formatJSON:
+		return &jsonReporter{w: w}, nil
+	case formatNDJSON:
+		return &ndjsonReporter{enc: json.NewEncoder(w)}, nil
+	case formatSARIF:
+		return &sarifReporter{w: w, rules: map[string]rule{}}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (must be one of: %s, %s, %s, %s)", format, formatText, formatJSON, formatNDJSON, formatSARIF)
+}
+
+// textReporter prints the human readable, tab indented report.
+type textReporter struct {
+	w io.Writer
+}
+
+func (t *textReporter) Report(r *repoReport) error {
+	output := fmt.Sprintf("%s -> \n", r.Repository)
+
+	if r.DefaultBranch != "" && !r.DefaultBranchProtected {
+		output += fmt.Sprintf("\tDefault Branch: %s (UNPROTECTED)\n", r.DefaultBranch)
+	}
+
+	if len(r.Collaborators) > 1 {
+		perms := map[string][]string{}
+		for _, c := range r.Collaborators {
+			teams, other := []string{}, []string{}
+			for _, s := range c.Sources {
+				if s.Type == sourceTeam {
+					teams = append(teams, s.String())
+				} else {
+					other = append(other, s.String())
+				}
+			}
+			line := fmt.Sprintf("\t\t\t%s (teams: %s)", c.Login, strings.Join(teams, ", "))
+			if len(other) > 0 {
+				line += " (" + strings.Join(other, ", ") + ")"
+			}
+			if c.RedundantDirectGrant {
+				line += " [redundant direct grant]"
+			}
+			if c.DirectGrantExceedsTeams {
+				line += " [direct grant exceeds teams]"
+			}
+			perms[c.Permission] = append(perms[c.Permission], line)
+		}
+		output += fmt.Sprintf("\tCollaborators (%d):\n", len(r.Collaborators))
+		output += fmt.Sprintf("\t\tAdmin (%d):\n%s\n", len(perms["ADMIN"]), strings.Join(perms["ADMIN"], "\n"))
+		output += fmt.Sprintf("\t\tMaintain (%d):\n%s\n", len(perms["MAINTAIN"]), strings.Join(perms["MAINTAIN"], "\n"))
+		output += fmt.Sprintf("\t\tTriage (%d):\n%s\n", len(perms["TRIAGE"]), strings.Join(perms["TRIAGE"], "\n"))
+		output += fmt.Sprintf("\t\tWrite (%d):\n%s\n", len(perms["WRITE"]), strings.Join(perms["WRITE"], "\n"))
+		output += fmt.Sprintf("\t\tRead (%d):\n%s\n", len(perms["READ"]), strings.Join(perms["READ"], "\n"))
+	}
+
+	if len(r.DeployKeys) > 0 {
+		kstr := []string{}
+		for _, k := range r.DeployKeys {
+			line := fmt.Sprintf("\t\t%s - ro:%t", k.Title, k.ReadOnly)
+			if k.URL != "" {
+				line += fmt.Sprintf(" (%s)", k.URL)
+			}
+			if k.Type != "" {
+				line += fmt.Sprintf(" %s %d bits %s", k.Type, k.Bits, k.Fingerprint)
+			}
+			line += fmt.Sprintf(" age:%dd verified:%t", k.AgeDays, k.Verified)
+			if k.Weak {
+				line += " [weak]"
+			}
+			kstr = append(kstr, line)
+		}
+		output += fmt.Sprintf("\tKeys (%d):\n%s\n", len(kstr), strings.Join(kstr, "\n"))
+	}
+
+	if len(r.Hooks) > 0 {
+		output += hooksText(r.Hooks)
+	}
+
+	if len(r.ProtectionRules) > 0 {
+		protectedBranches := []string{}
+		for _, p := range r.ProtectionRules {
+			protectedBranches = append(protectedBranches, p.Pattern)
+		}
+		output += fmt.Sprintf("\tProtected Branches (%d): %s\n", len(protectedBranches), strings.Join(protectedBranches, ", "))
+		for _, p := range r.ProtectionRules {
+			output += fmt.Sprintf("\t\t%s - %s\n", p.Pattern, p.settings())
+		}
+	}
+
+	if len(r.UnprotectedBranches) > 0 {
+		output += fmt.Sprintf("\tUnprotected Branches (%d): %s\n", len(r.UnprotectedBranches), strings.Join(r.UnprotectedBranches, ", "))
+	}
+
+	mergeMethods := "\tMerge Methods:"
+	for _, m := range r.MergeMethods {
+		mergeMethods += " " + m
+	}
+	output += mergeMethods + "\n"
+
+	if r.Actions != nil {
+		output += r.Actions.text()
+	}
+
+	if len(r.Environments) > 0 {
+		estr := []string{}
+		for _, e := range r.Environments {
+			line := fmt.Sprintf("\t\t%s - reviewers:[%s] waitTimer:%dm branches:%s", e.Name, strings.Join(e.RequiredReviewers, ", "), e.WaitTimer, e.BranchPolicy)
+			if e.BranchPolicy == branchPolicyCustom {
+				line += fmt.Sprintf(" [%s]", strings.Join(e.BranchPatterns, ", "))
+			}
+			if e.Production {
+				line += " [production]"
+			}
+			estr = append(estr, line)
+			if len(e.Secrets) > 0 {
+				estr = append(estr, fmt.Sprintf("\t\t\tSecrets (%d): %s", len(e.Secrets), strings.Join(e.Secrets, ", ")))
+			}
+		}
+		output += fmt.Sprintf("\tEnvironments (%d):\n%s\n", len(r.Environments), strings.Join(estr, "\n"))
+	}
+
+	if len(r.Workflows) > 0 {
+		wstr := []string{}
+		for _, w := range r.Workflows {
+			wstr = append(wstr, fmt.Sprintf("\t\t%s - on:[%s]", w.Path, strings.Join(w.Triggers, ", ")))
+			for _, i := range w.Issues {
+				wstr = append(wstr, fmt.Sprintf("\t\t\t[%s] %s: %s", auditRules[i.Rule].Severity, i.Rule, i.Message))
+			}
+		}
+		output += fmt.Sprintf("\tWorkflows (%d):\n%s\n", len(r.Workflows), strings.Join(wstr, "\n"))
+	}
+
+	if len(r.Policy) > 0 {
+		failed := []string{}
+		for _, p := range r.Policy {
+			if !p.Passed {
+				failed = append(failed, p.Rule)
+			}
+		}
+		output += fmt.Sprintf("\tPolicy: %d passed, %d failed", len(r.Policy)-len(failed), len(failed))
+		if len(failed) > 0 {
+			output += fmt.Sprintf(" (%s)", strings.Join(failed, ", "))
+		}
+		output += "\n"
+	}
+
+	output += findingsText(r.Findings, r.Suppressed, r.NotVisible, r.Errors)
+
+	_, err := fmt.Fprintf(t.w, "%s--\n\n", output)
+	return err
+}
+
+func (t *textReporter) ReportOrg(o *orgReport) error {
+	output := fmt.Sprintf("%s (organization) -> \n", o.Organization)
+
+	if o.DefaultRepositoryPermission != "" {
+		output += fmt.Sprintf("\tDefault Repository Permission: %s\n", o.DefaultRepositoryPermission)
+		output += fmt.Sprintf("\tMembers Can: createPublic:%s createPrivate:%s forkPrivate:%s\n",
+			optionalBool(o.MembersCanCreatePublicRepositories), optionalBool(o.MembersCanCreatePrivateRepositories),
+			optionalBool(o.MembersCanForkPrivateRepositories))
+		output += fmt.Sprintf("\t2FA Required: %s\n", optionalBool(o.TwoFactorRequirementEnabled))
+	}
+
+	output += fmt.Sprintf("\tOwners (%d): %s\n", len(o.Owners), strings.Join(o.Owners, ", "))
+	if len(o.MembersWithoutTwoFactor) > 0 {
+		output += fmt.Sprintf("\tMembers Without 2FA (%d): %s\n", len(o.MembersWithoutTwoFactor), strings.Join(o.MembersWithoutTwoFactor, ", "))
+	}
+	output += fmt.Sprintf("\tOutside Collaborators: %d\n", o.OutsideCollaborators)
+	if len(o.VerifiedDomains) > 0 {
+		output += fmt.Sprintf("\tVerified Domains (%d): %s\n", len(o.VerifiedDomains), strings.Join(o.VerifiedDomains, ", "))
+	}
+
+	if len(o.Hooks) > 0 {
+		output += hooksText(o.Hooks)
+	}
+
+	if len(o.Installations) > 0 {
+		istr := []string{}
+		for _, i := range o.Installations {
+			perms := []string{}
+			for name, level := range i.Permissions {
+				perms = append(perms, name+":"+level)
+			}
+			sort.Strings(perms)
+			line := fmt.Sprintf("\t\t%s - repositories:%s", i.App, i.RepositorySelection)
+			if i.RepositorySelection == selectionSelected {
+				line += " (not listable with a user token)"
+			}
+			line += fmt.Sprintf(" permissions:[%s] events:[%s]", strings.Join(perms, ", "), strings.Join(i.Events, ", "))
+			if i.Suspended {
+				line += " [suspended]"
+			}
+			istr = append(istr, line)
+		}
+		output += fmt.Sprintf("\tApps (%d):\n%s\n", len(istr), strings.Join(istr, "\n"))
+	}
+
+	output += fmt.Sprintf("\tOAuth App Restrictions: %s\n", optionalBool(o.OAuthAppRestrictions))
+	if len(o.ApprovedOAuthApps) > 0 {
+		output += fmt.Sprintf("\tApproved OAuth Apps (%d): %s\n", len(o.ApprovedOAuthApps), strings.Join(o.ApprovedOAuthApps, ", "))
+	}
+
+	output += findingsText(o.Findings, o.Suppressed, o.NotVisible, o.Errors)
+
+	_, err := fmt.Fprintf(t.w, "%s--\n\n", output)
+	return err
+}
+
+// hooksText returns the hooks section of the text report.
+func hooksText(hooks []hookReport) string {
+	hstr := []string{}
+	for _, h := range hooks {
+		line := fmt.Sprintf("\t\t%s - active:%t (%s)", h.Name, h.Active, h.URL)
+		if h.Target != "" {
+			line += fmt.Sprintf(" -> %s events:[%s] secret:%t insecureSSL:%t", h.Target, strings.Join(h.Events, ", "), h.HasSecret, h.InsecureSSL)
+			if h.ApprovedHost != nil && !*h.ApprovedHost {
+				line += " [unapproved host]"
+			}
+		}
+		if h.Health != "" {
+			line += " health:" + h.Health
+		}
+		hstr = append(hstr, line)
+	}
+	return fmt.Sprintf("\tHooks (%d):\n%s\n", len(hstr), strings.Join(hstr, "\n"))
+}
+
+// findingsText returns the findings, suppressed findings, not visible and
+// errors sections of the text report.
+func findingsText(findings, suppressed []finding, notVisible, errs []string) string {
+	output := ""
+
+	if len(findings) > 0 {
+		fstr := []string{}
+		for _, f := range findings {
+			fstr = append(fstr, fmt.Sprintf("\t\t[%s] %s: %s", f.Severity, f.RuleID, f.Message))
+		}
+		output += fmt.Sprintf("\tFindings (%d):\n%s\n", len(fstr), strings.Join(fstr, "\n"))
+	}
+
+	if len(suppressed) > 0 {
+		output += fmt.Sprintf("\tSuppressed Findings: %d\n", len(suppressed))
+	}
+
+	if len(notVisible) > 0 {
+		output += fmt.Sprintf("\tNot Visible With This Token (%d): %s\n", len(notVisible), strings.Join(notVisible, ", "))
+	}
+
+	if len(errs) > 0 {
+		estr := []string{}
+		for _, e := range errs {
+			estr = append(estr, "\t\t"+e)
+		}
+		output += fmt.Sprintf("\tErrors (%d):\n%s\n", len(estr), strings.Join(estr, "\n"))
+	}
+
+	return output
+}
+
+// optionalBool formats a setting that may not be visible.
+func optionalBool(b *bool) string {
+	if b == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%t", *b)
+}
+
+func (t *textReporter) Close() error {
+	return nil
+}
+
+// jsonReporter buffers every report and writes a single document on Close.
+type jsonReporter struct {
+	w      io.Writer
+	report auditReport
+}
+
+func (j *jsonReporter) Report(r *repoReport) error {
+	j.report.Repositories = append(j.report.Repositories, r)
+	return nil
+}
+
+func (j *jsonReporter) ReportOrg(o *orgReport) error {
+	j.report.Organizations = append(j.report.Organizations, o)
+	return nil
+}
+
+func (j *jsonReporter) Close() error {
+	if j.report.Repositories == nil {
+		j.report.Repositories = []*repoReport{}
+	}
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j.report)
+}
+
+// Kinds of the documents written by the ndjson reporter.
+const (
+	kindRepository   = "repository"
+	kindOrganization = "organization"
+)
+
+// ndjsonReporter writes one JSON document per repository, or organization, as
+// soon as it is available. The kind field of every document tells which one
+// it is.
+type ndjsonReporter struct {
+	enc *json.Encoder
+}
+
+// ndjsonRepo is a repository document of the ndjson output.
+type ndjsonRepo struct {
+	Kind string `json:"kind"`
+	*repoReport
+}
+
+// ndjsonOrg is an organization document of the ndjson output.
+type ndjsonOrg struct {
+	Kind string `json:"kind"`
+	*orgReport
+}
+
+func (n *ndjsonReporter) Report(r *repoReport) error {
+	return n.enc.Encode(ndjsonRepo{kindRepository, r})
+}
+
+func (n *ndjsonReporter) ReportOrg(o *orgReport) error {
+	return n.enc.Encode(ndjsonOrg{kindOrganization, o})
+}
+
+func (n *ndjsonReporter) Close() error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestNDJSONReporterKind(t *testing.T) {
+	var b bytes.Buffer
+	rep, err := newReporter(formatNDJSON, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reportOrg(rep, &orgReport{Organization: "genuinetools", NotVisible: []string{"settings"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rep.Report(&repoReport{Repository: "genuinetools/audit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(&b)
+	for _, want := range []struct{ kind, name string }{
+		{kindOrganization, "genuinetools"},
+		{kindRepository, "genuinetools/audit"},
+	} {
+		var doc struct {
+			Kind         string   `json:"kind"`
+			Organization string   `json:"organization"`
+			Repository   string   `json:"repository"`
+			NotVisible   []string `json:"notVisible"`
+		}
+		if err := dec.Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Kind != want.kind || doc.Organization+doc.Repository != want.name {
+			t.Errorf("document = %+v, want a %s document for %s", doc, want.kind, want.name)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/genuinetools/audit/version"
+)
+
+const (
+	formatSARIF = "sarif"
+
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+// The following types are the subset of the SARIF 2.1.0 object model that
+// audit emits.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name,omitempty"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           map[string]string  `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifLevel maps a severity to a SARIF result level.
+func sarifLevel(s severity) string {
+	switch s {
+	case severityHigh, severityCritical:
+		return "error"
+	case severityMedium:
+		return "warning"
+	}
+	return "note"
+}
+
+// sarifReporter collects the findings of every repository and organization
+// and writes a single SARIF log on Close.
+type sarifReporter struct {
+	w       io.Writer
+	rules   map[string]rule
+	results []sarifResult
+}
+
+func (s *sarifReporter) Report(r *repoReport) error {
+	s.addResults(r.Findings, r.URL)
+	return nil
+}
+
+func (s *sarifReporter) ReportOrg(o *orgReport) error {
+	s.addResults(o.Findings, o.URL)
+	return nil
+}
+
+// addResults adds a result for each of the findings, located at uri.
+func (s *sarifReporter) addResults(findings []finding, uri string) {
+	for _, f := range findings {
+		if _, ok := s.rules[f.RuleID]; !ok {
+			s.rules[f.RuleID] = ruleForFinding(f)
+		}
+		s.results = append(s.results, sarifResult{
+			RuleID:  f.RuleID,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{
+				{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: uri},
+					},
+				},
+			},
+			PartialFingerprints: map[string]string{
+				"auditFingerprint/v1": f.fingerprint(),
+			},
+		})
+	}
+}
+
+func (s *sarifReporter) Close() error {
+	// Sort the rules so the rule indexes are stable between runs.
+	ids := []string{}
+	for id := range s.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rules := []sarifRule{}
+	index := map[string]int{}
+	for i, id := range ids {
+		r := s.rules[id]
+		index[id] = i
+		rules = append(rules, sarifRule{
+			ID:                   r.ID,
+			Name:                 r.Name,
+			ShortDescription:     sarifMessage{Text: r.Description},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(r.Severity)},
+			Properties:           map[string]string{"severity": string(r.Severity)},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, res := range s.results {
+		res.RuleIndex = index[res.RuleID]
+		results = append(results, res)
+	}
+
+	enc := json.NewEncoder(s.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "audit",
+						InformationURI: "https://github.com/genuinetools/audit",
+						Version:        version.VERSION,
+						Rules:          rules,
+					},
+				},
+				Results: results,
+			},
+		},
+	})
+}
+
+// ruleForFinding returns the rule that produced the finding, falling back to
+// a rule built from the finding itself for rules that are not built in.
+func ruleForFinding(f finding) rule {
+	if r, ok := auditRules[f.RuleID]; ok {
+		return r
+	}
+	return rule{
+		ID:          f.RuleID,
+		Description: f.RuleID,
+		Severity:    f.Severity,
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestSARIFGolden(t *testing.T) {
+	var buf bytes.Buffer
+	rep, err := newReporter(formatSARIF, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newRepoReport(ghrepo{NameWithOwner: "genuinetools/audit"})
+	r.Findings = []finding{
+		newFinding(ruleDeployKeyWriteAccess, r.Repository, "DK_kwDOA", "deploy key jenkins can push to the repository"),
+		newFinding(ruleHookNoSecret, r.Repository, "1234", "hook 1234 has no secret"),
+		// Rules that are not built in, like policy rules, get a rule of
+		// their own.
+		{RuleID: "policy-max-admins", Severity: severityLow, Repository: r.Repository, Identifier: "admins", Message: "5 admins"},
+	}
+	if err := rep.Report(r); err != nil {
+		t.Fatal(err)
+	}
+	o := &orgReport{Organization: "genuinetools", URL: "https://github.com/genuinetools"}
+	o.Findings = []finding{
+		newFinding(ruleOrgTwoFactorNotRequired, o.Organization, "two_factor_requirement_enabled", "two-factor authentication is not required"),
+	}
+	if err := reportOrg(rep, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "audit.sarif")
+	if *update {
+		if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("SARIF log differs from %s, run go test -update to see the changes:\n%s", golden, buf.String())
+	}
+
+	// Code scanning rejects results whose index points at another rule.
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	rules := log.Runs[0].Tool.Driver.Rules
+	for _, res := range log.Runs[0].Results {
+		if res.RuleIndex >= len(rules) || rules[res.RuleIndex].ID != res.RuleID {
+			t.Errorf("result of %s has rule index %d", res.RuleID, res.RuleIndex)
+		}
+	}
+}
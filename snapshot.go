@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/genuinetools/audit/version"
+)
+
+// snapshot is a saved audit run that can later be compared to another one
+// with the diff command. The document written with -format json has the same
+// repositories field, so it can be used as a snapshot too.
+type snapshot struct {
+	Version       string        `json:"version,omitempty"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	Organizations []*orgReport  `json:"organizations,omitempty"`
+	Repositories  []*repoReport `json:"repositories"`
+}
+
+// snapshotReporter wraps a reporter and saves every report to a snapshot file
+// once the run is over.
+type snapshotReporter struct {
+	reporter
+	path string
+
+	snapshot snapshot
+}
+
+// newSnapshotReporter returns a snapshotReporter saving the run to path.
+func newSnapshotReporter(rep reporter, path string) *snapshotReporter {
+	return &snapshotReporter{
+		reporter: rep,
+		path:     path,
+		snapshot: snapshot{
+			Version:      version.VERSION,
+			CreatedAt:    time.Now().UTC(),
+			Repositories: []*repoReport{},
+		},
+	}
+}
+
+func (s *snapshotReporter) Report(r *repoReport) error {
+	s.snapshot.Repositories = append(s.snapshot.Repositories, r)
+	return s.reporter.Report(r)
+}
+
+func (s *snapshotReporter) ReportOrg(o *orgReport) error {
+	s.snapshot.Organizations = append(s.snapshot.Organizations, o)
+	return reportOrg(s.reporter, o)
+}
+
+func (s *snapshotReporter) Close() error {
+	if err := s.reporter.Close(); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(s.snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing snapshot failed: %v", err)
+	}
+	return nil
+}
+
+// loadSnapshot reads the snapshot file at path.
+func loadSnapshot(path string) (*snapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot failed: %v", err)
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s failed: %v", path, err)
+	}
+	if s.Repositories == nil {
+		return nil, fmt.Errorf("%s is not an audit snapshot: it has no repositories", path)
+	}
+	return &s, nil
+}
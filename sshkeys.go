@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// minRSABits is the smallest RSA key size that is not flagged as weak.
+const minRSABits = 2048
+
+// sshKey describes an SSH public key.
+type sshKey struct {
+	// Type is the key type, for example ssh-rsa or ssh-ed25519.
+	Type string
+	// Bits is the size of the key.
+	Bits int
+	// Fingerprint is the SHA256 fingerprint of the key, as printed by
+	// ssh-keygen -l.
+	Fingerprint string
+}
+
+// parseSSHKey parses a public key in the authorized_keys format GitHub
+// returns deploy keys in: "<type> <base64 blob> [comment]".
+func parseSSHKey(s string) (sshKey, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return sshKey{}, errors.New("invalid public key")
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return sshKey{}, fmt.Errorf("invalid public key: %v", err)
+	}
+
+	sum := sha256.Sum256(blob)
+	key := sshKey{
+		Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+	}
+
+	// The blob is a sequence of length prefixed strings and integers,
+	// starting with the key type.
+	r := &wireReader{b: blob}
+	key.Type = string(r.next())
+	switch key.Type {
+	case "ssh-rsa":
+		r.next() // public exponent
+		key.Bits = r.mpintBits()
+	case "ssh-dss":
+		key.Bits = r.mpintBits() // p
+	case "ecdsa-sha2-nistp256", "sk-ecdsa-sha2-nistp256@openssh.com":
+		key.Bits = 256
+	case "ecdsa-sha2-nistp384":
+		key.Bits = 384
+	case "ecdsa-sha2-nistp521":
+		key.Bits = 521
+	case "ssh-ed25519", "sk-ssh-ed25519@openssh.com":
+		key.Bits = 256
+	}
+	if r.err != nil {
+		return sshKey{}, fmt.Errorf("invalid %s public key: %v", fields[0], r.err)
+	}
+	return key, nil
+}
+
+// weak reports whether the key type or size is considered breakable.
+func (k sshKey) weak() bool {
+	switch k.Type {
+	case "ssh-dss":
+		return true
+	case "ssh-rsa":
+		return k.Bits < minRSABits
+	}
+	return false
+}
+
+// wireReader reads the SSH wire format of a public key.
+type wireReader struct {
+	b   []byte
+	err error
+}
+
+// next returns the next length prefixed value.
+func (r *wireReader) next() []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < 4 {
+		r.err = errors.New("short read")
+		return nil
+	}
+	n := binary.BigEndian.Uint32(r.b)
+	if uint32(len(r.b)-4) < n {
+		r.err = errors.New("short read")
+		return nil
+	}
+	v := r.b[4 : 4+n]
+	r.b = r.b[4+n:]
+	return v
+}
+
+// mpintBits returns the size in bits of the next multiple precision integer.
+func (r *wireReader) mpintBits() int {
+	return new(big.Int).SetBytes(r.next()).BitLen()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// The keys and fingerprints below were generated and printed with ssh-keygen.
+const (
+	testRSA2048Key  = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDYTIM8eN2RqcnToGQDQtGfUX1xZPnD95XJlvYLlzHwCJ6VCXufs/QUgeWXjSW+4eoPTowu3zMiCShB7Z2uqMlBxTQRVEhvjFTt3KEmvMYiQglhlWblyNrP87Gar+LRgkNpwNvIL8b57N7FQbE4kDHMNVokyVvEnR2IKBDakYB4eIlvyxKVRr1VHqMdp/S5cnBdCYWNTobxBtmK+BxlTY0sGOAukjWBKVsoQuhf1DkUyWIEJ5WfW6IkStYnS5AY62eIxxBv3xmD8ZhRixriC8D/LJu443QXcwabitnD5C8Iw3POQP+oV8VLonNgWRNnX4MD0dNll6FZ6NqvlbJfHM+3 rsab2048@audit"
+	testRSA1024Key  = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQCziYLYOdPgVv6SVvIJPtfHfGIcaNQXms9BlylKSbeO2ONwYbpWMz5Zs86Fnwoq7TL92WanVIXEjUEL1ozXjsxpi91ZJXRQcoxh67Ok+BqbqTwM2BuWVBLHgyUIytQV3eMb874/7DrxiiAHvmNn/EzEaXBNBYSVg9sr8OseEY+BBw== rsab1024@audit"
+	testEd25519Key  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJhevss4pr+XBK51kFrbF7iBtKVQXRJS1r9B/QUs+V18 ed25519@audit"
+	testECDSA256Key = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBJ9GU8N2n+XysZsfRDBwFgZIVCAGgPMIWPWKmMu9Gjd7OFPsHFo1c/Krj/gagYn4ikG/4HoPWLVnMcxPanLeH8I= ecdsab256@audit"
+	testECDSA384Key = "ecdsa-sha2-nistp384 AAAAE2VjZHNhLXNoYTItbmlzdHAzODQAAAAIbmlzdHAzODQAAABhBOsB0R8dqwe4vU18FvHq6l878e+f2oVRBsA7nOMyPW/qFdaA42arxF+ppwbUFryJu3PLWMGbBuaAR/f10WNYCPvRXaJskS+pUbnpBfvUXxHJiX2WJrjZ3gXffhSEVGzbJQ== ecdsab384@audit"
+	testECDSA521Key = "ecdsa-sha2-nistp521 AAAAE2VjZHNhLXNoYTItbmlzdHA1MjEAAAAIbmlzdHA1MjEAAACFBACMOVVxdGEaLX70tf+ZFK8UlHzrtst148i5R9T0KO6iLz1K9QjEY8Y7v/GumvGD3Oz5QzgHkZOljxCnoqqV9Ptx6AGfiuNrP+LEddQxtG2sQPUtpBPGrhYM1wlRcDFpKfx08IfPNoWzMBnDLe2k/tQjaA+02zVHF3crsuuOLpbQLtKrPg== ecdsab521@audit"
+	testDSAKey      = "ssh-dss AAAAB3NzaC1kc3MAAACBANBAlt+m/Y8+RevB9MLWo7OXQ/e4pkeB/6u+Zkh3VBxdnrVt4sRWlYJyAXFDCsqxRo2YB0NQYrJ2nT5kZEvBrqU6SHNMuR/xxLd9MhncYYnARZdT7+0Ekx1c72zA+0dkTM51gFeoWuIvJzlb0XJsbw7Z2dwkBbNgaREWW/f9AraTAAAAFQC6KXy932w2FhYelvYp0pw4+mMOGwAAAIBMjAFSAbgoZ2xpD03uXIJ8tsjvMwtUfZshPGDDBTTJyjr0oK5AWa1RHRai3lkspWBVRb8VPwFBCehNDUI6Sc1VJnbNZ95SqzVDloStI3VptCR+rmr/A4+L2SryA4Q9M9tox6i9v4gXL6egUvbeXKindI3uOTsd7lmj+PHd+bEU5wAAAIBKR5LA01NF2LHrog0ryF5tn2ygJXEbhPO9MUAcXt6s0q4O98E1MQRSxiYhG0iFi/GP4Ee49dUQrD/N8QzXQl5MVcBLvUqs3/y/VDmIUZpOpa744lDxdSsY8Gy1FsAigaBWSSXpSw6ynMdElRe/PkYDAVijLjcH9PjjhbIDOTygWQ== dsa@audit"
+)
+
+func TestParseSSHKey(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		key  string
+		want sshKey
+		weak bool
+	}{
+		{"rsa 2048", testRSA2048Key, sshKey{"ssh-rsa", 2048, "SHA256:iC+KvAuEp8pDuR3EYEWzlz7RVtCPY5nKXmOyohMNsZQ"}, false},
+		{"rsa 1024", testRSA1024Key, sshKey{"ssh-rsa", 1024, "SHA256:rImYq4KbKnpBCaPO6SC4Pa6aG5aXhtmmOPMVBwWtGE4"}, true},
+		{"ed25519", testEd25519Key, sshKey{"ssh-ed25519", 256, "SHA256:J/iIWaE8KEhk4tYg8IbAmSU46Nuo4So4OFgo6IHwA58"}, false},
+		{"ecdsa 256", testECDSA256Key, sshKey{"ecdsa-sha2-nistp256", 256, "SHA256:ZCKUUoinNg5U+RVuLJijqxF9nil9uVeEhxBbJizj8g4"}, false},
+		{"ecdsa 384", testECDSA384Key, sshKey{"ecdsa-sha2-nistp384", 384, "SHA256:j2OkpyevX3h+7RVlHR805ky/SIydTR1f8ct1A8nwKtk"}, false},
+		{"ecdsa 521", testECDSA521Key, sshKey{"ecdsa-sha2-nistp521", 521, "SHA256:7259eaFL6zs7YzncYoRzuEq9Fy30y8wbIC6TfDQkV1Q"}, false},
+		{"dsa", testDSAKey, sshKey{"ssh-dss", 1024, "SHA256:1mjxkyyw6xZ0VpkCUiOD2YM45Z9lJWa+Vn9EssFkY+I"}, true},
+		{"no comment", strings.TrimSuffix(testEd25519Key, " ed25519@audit"), sshKey{"ssh-ed25519", 256, "SHA256:J/iIWaE8KEhk4tYg8IbAmSU46Nuo4So4OFgo6IHwA58"}, false},
+	} {
+		got, err := parseSSHKey(tc.key)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: parseSSHKey = %+v, want %+v", tc.name, got, tc.want)
+		}
+		if got.weak() != tc.weak {
+			t.Errorf("%s: weak = %t, want %t", tc.name, got.weak(), tc.weak)
+		}
+	}
+}
+
+func TestParseSSHKeyInvalid(t *testing.T) {
+	blob, err := base64.StdEncoding.DecodeString(strings.Fields(testRSA2048Key)[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		key  string
+		err  string
+	}{
+		{"empty", "", "invalid public key"},
+		{"type only", "ssh-rsa", "invalid public key"},
+		{"bad base64", "ssh-rsa AAAA!!!!", "invalid public key: illegal base64"},
+		// The modulus is cut short.
+		{"truncated", "ssh-rsa " + base64.StdEncoding.EncodeToString(blob[:len(blob)-10]), "invalid ssh-rsa public key: short read"},
+		// Not even the length of the key type fits.
+		{"truncated length", "ssh-rsa " + base64.StdEncoding.EncodeToString(blob[:3]), "invalid ssh-rsa public key: short read"},
+	} {
+		_, err := parseSSHKey(tc.key)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: error = %v, want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestSSHKeyWeak(t *testing.T) {
+	for _, tc := range []struct {
+		key  sshKey
+		want bool
+	}{
+		{sshKey{Type: "ssh-rsa", Bits: minRSABits - 1}, true},
+		{sshKey{Type: "ssh-rsa", Bits: minRSABits}, false},
+		{sshKey{Type: "ssh-rsa", Bits: 4096}, false},
+		{sshKey{Type: "ssh-dss", Bits: 1024}, true},
+		{sshKey{Type: "ssh-dss", Bits: 3072}, true},
+		{sshKey{Type: "ssh-ed25519", Bits: 256}, false},
+		{sshKey{Type: "ecdsa-sha2-nistp256", Bits: 256}, false},
+		{sshKey{Type: "sk-ssh-ed25519@openssh.com", Bits: 256}, false},
+	} {
+		if got := tc.key.weak(); got != tc.want {
+			t.Errorf("%s %d bits: weak = %t, want %t", tc.key.Type, tc.key.Bits, got, tc.want)
+		}
+	}
+}
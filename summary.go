@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Exit codes of an audit run. A hard failure, where the audit could not run
+// at all, exits with 1.
+const (
+	// exitFindings is used when there are findings at or above the -fail-on
+	// severity.
+	exitFindings = 2
+	// exitIncomplete is used when some repositories or organizations could
+	// not be fully audited.
+	exitIncomplete = 3
+)
+
+// summaryReporter wraps a reporter and keeps track of everything that could
+// not be fully audited during the run so it can be summarized at the end.
+type summaryReporter struct {
+	reporter
+	w io.Writer
+
+	// incomplete holds the repositories that had errors.
+	incomplete []*repoReport
+	// incompleteOrgs holds the organizations that had errors.
+	incompleteOrgs []*orgReport
+	// failures holds the users or organizations whose repositories, or
+	// settings, could not be listed at all.
+	failures []string
+	// findings counts the findings reported per severity.
+	findings map[severity]int
+	// unapprovedHosts holds, per host that is not in the allowlist, the
+	// repositories with a webhook delivering to it.
+	unapprovedHosts map[string][]string
+	hosts           []string
+	// keyRepos holds, per deploy key fingerprint, the repositories it has
+	// access to.
+	keyRepos     map[string][]string
+	fingerprints []string
+}
+
+func (s *summaryReporter) Report(r *repoReport) error {
+	if len(r.Errors) > 0 {
+		s.incomplete = append(s.incomplete, r)
+	}
+	s.countFindings(r.Findings)
+	for _, k := range r.DeployKeys {
+		if k.Fingerprint == "" {
+			continue
+		}
+		if s.keyRepos == nil {
+			s.keyRepos = map[string][]string{}
+		}
+		repos, ok := s.keyRepos[k.Fingerprint]
+		if !ok {
+			s.fingerprints = append(s.fingerprints, k.Fingerprint)
+		}
+		if len(repos) < 1 || repos[len(repos)-1] != r.Repository {
+			s.keyRepos[k.Fingerprint] = append(repos, r.Repository)
+		}
+	}
+	for _, h := range r.Hooks {
+		if h.ApprovedHost == nil || *h.ApprovedHost {
+			continue
+		}
+		if s.unapprovedHosts == nil {
+			s.unapprovedHosts = map[string][]string{}
+		}
+		repos, ok := s.unapprovedHosts[h.Host]
+		if !ok {
+			s.hosts = append(s.hosts, h.Host)
+		}
+		if len(repos) < 1 || repos[len(repos)-1] != r.Repository {
+			s.unapprovedHosts[h.Host] = append(repos, r.Repository)
+		}
+	}
+	return s.reporter.Report(r)
+}
+
+func (s *summaryReporter) ReportOrg(o *orgReport) error {
+	if len(o.Errors) > 0 {
+		s.incompleteOrgs = append(s.incompleteOrgs, o)
+	}
+	s.countFindings(o.Findings)
+	return reportOrg(s.reporter, o)
+}
+
+// countFindings adds the findings to the count per severity.
+func (s *summaryReporter) countFindings(findings []finding) {
+	if s.findings == nil {
+		s.findings = map[severity]int{}
+	}
+	for _, f := range findings {
+		s.findings[f.Severity]++
+	}
+}
+
+// failed records that the repositories of a user or organization, or the
+// settings of an organization, could not be audited.
+func (s *summaryReporter) failed(login string, err error) {
+	s.failures = append(s.failures, fmt.Sprintf("%s: %v", login, err))
+}
+
+func (s *summaryReporter) Close() error {
+	if err := s.reporter.Close(); err != nil {
+		return err
+	}
+
+	if len(s.failures) > 0 {
+		fmt.Fprintf(s.w, "Could not audit %d users or organizations:\n", len(s.failures))
+		for _, f := range s.failures {
+			fmt.Fprintf(s.w, "\t%s\n", f)
+		}
+	}
+
+	if len(s.incompleteOrgs) > 0 {
+		fmt.Fprintf(s.w, "Could not fully audit %d organizations:\n", len(s.incompleteOrgs))
+		for _, o := range s.incompleteOrgs {
+			for _, e := range o.Errors {
+				fmt.Fprintf(s.w, "\t%s: %s\n", o.Organization, e)
+			}
+		}
+	}
+
+	if len(s.incomplete) > 0 {
+		fmt.Fprintf(s.w, "Could not fully audit %d repositories:\n", len(s.incomplete))
+		for _, r := range s.incomplete {
+			for _, e := range r.Errors {
+				fmt.Fprintf(s.w, "\t%s: %s\n", r.Repository, e)
+			}
+		}
+	}
+
+	if len(s.hosts) > 0 {
+		fmt.Fprintf(s.w, "Webhooks deliver to %d unapproved hosts:\n", len(s.hosts))
+		for _, h := range s.hosts {
+			fmt.Fprintf(s.w, "\t%s: %s\n", h, strings.Join(s.unapprovedHosts[h], ", "))
+		}
+	}
+
+	reused := []string{}
+	for _, f := range s.fingerprints {
+		if len(s.keyRepos[f]) > 1 {
+			reused = append(reused, f)
+		}
+	}
+	if len(reused) > 0 {
+		fmt.Fprintf(s.w, "Deploy keys used on more than one repository (%d):\n", len(reused))
+		for _, f := range reused {
+			fmt.Fprintf(s.w, "\t%s: %s\n", f, strings.Join(s.keyRepos[f], ", "))
+		}
+	}
+
+	fmt.Fprintf(s.w, "Findings: %d critical, %d high, %d medium, %d low\n",
+		s.findings[severityCritical], s.findings[severityHigh], s.findings[severityMedium], s.findings[severityLow])
+
+	return nil
+}
+
+// exitCode returns the exit code of the run: exitFindings if there are
+// findings at or above the threshold, which is ignored when empty, then
+// exitIncomplete if anything could not be audited, 0 otherwise.
+func (s *summaryReporter) exitCode(threshold severity) int {
+	if threshold != "" {
+		for sev, n := range s.findings {
+			if n > 0 && sev.rank() >= threshold.rank() {
+				return exitFindings
+			}
+		}
+	}
+	if len(s.failures) > 0 || len(s.incomplete) > 0 || len(s.incompleteOrgs) > 0 {
+		return exitIncomplete
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// suppressionDateFormat is the format of the expiry date of a suppression.
+const suppressionDateFormat = "2006-01-02"
+
+// suppressions holds the findings that were reviewed and accepted, so they
+// are left out of the report.
+//
+// A suppressions file looks like:
+//
+//	suppressions:
+//	  - fingerprint: genuinetools/audit:deploy-key-write-access:MDk6UHVibGljS2V5MTIzNA==
+//	    reason: CI deploy key
+//	  - fingerprint: genuinetools/audit:hook-inactive:5678
+//	    reason: Travis hook, disabled while we migrate
+//	    expires: 2019-06-30
+type suppressions struct {
+	Suppressions []suppression `yaml:"suppressions"`
+
+	// byFingerprint indexes the suppressions by fingerprint.
+	byFingerprint map[string]suppression
+}
+
+// suppression accepts the finding with the fingerprint, until it expires if
+// an expiry date is set.
+type suppression struct {
+	// Fingerprint is the fingerprint of the finding:
+	// <repository>:<rule id>:<identifier>.
+	Fingerprint string `yaml:"fingerprint"`
+	Reason      string `yaml:"reason"`
+	// Expires is the date, as YYYY-MM-DD, from which the finding is
+	// reported again.
+	Expires string `yaml:"expires"`
+
+	expires time.Time
+}
+
+// loadSuppressions reads and validates the suppressions file at path.
+func loadSuppressions(path string) (*suppressions, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading suppressions file failed: %v", err)
+	}
+
+	var s suppressions
+	if err := yaml.UnmarshalStrict(b, &s); err != nil {
+		return nil, fmt.Errorf("parsing suppressions file %s failed: %v", path, err)
+	}
+
+	s.byFingerprint = map[string]suppression{}
+	for i, sup := range s.Suppressions {
+		if sup.Fingerprint == "" {
+			return nil, fmt.Errorf("suppression %d in suppressions file %s has no fingerprint", i+1, path)
+		}
+		if _, ok := s.byFingerprint[sup.Fingerprint]; ok {
+			return nil, fmt.Errorf("%s is suppressed more than once in suppressions file %s", sup.Fingerprint, path)
+		}
+		if sup.Expires != "" {
+			sup.expires, err = time.Parse(suppressionDateFormat, sup.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("suppression of %s in suppressions file %s has invalid expiry date %q (must be YYYY-MM-DD)", sup.Fingerprint, path, sup.Expires)
+			}
+		}
+		s.byFingerprint[sup.Fingerprint] = sup
+	}
+
+	return &s, nil
+}
+
+// apply moves the findings of the report that are suppressed, and whose
+// suppression did not expire, out of its findings. The workflow issues behind
+// them are dropped as well so no reporter prints them.
+func (s *suppressions) apply(r *repoReport, now time.Time) {
+	r.Findings, r.Suppressed = s.filter(r.Findings, now)
+
+	suppressed := map[string]bool{}
+	for _, f := range r.Suppressed {
+		suppressed[f.fingerprint()] = true
+	}
+	for i, w := range r.Workflows {
+		issues := []workflowIssue{}
+		for _, issue := range w.Issues {
+			if !suppressed[newFinding(issue.Rule, r.Repository, issue.Identifier, issue.Message).fingerprint()] {
+				issues = append(issues, issue)
+			}
+		}
+		r.Workflows[i].Issues = issues
+	}
+}
+
+// filter splits the findings into the ones to report and the ones that are
+// suppressed and whose suppression did not expire.
+func (s *suppressions) filter(findings []finding, now time.Time) ([]finding, []finding) {
+	kept, suppressed := []finding{}, []finding(nil)
+	for _, f := range findings {
+		sup, ok := s.byFingerprint[f.fingerprint()]
+		if !ok {
+			kept = append(kept, f)
+			continue
+		}
+		if !sup.expires.IsZero() && !now.Before(sup.expires) {
+			logrus.Warnf("Suppression of %s expired on %s, reporting it again", sup.Fingerprint, sup.Expires)
+			kept = append(kept, f)
+			continue
+		}
+		suppressed = append(suppressed, f)
+	}
+	return kept, suppressed
+}
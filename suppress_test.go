@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSuppressions writes the suppressions file to a temporary directory and
+// returns its path.
+func writeSuppressions(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name+".yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSuppressions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit-suppressions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		// err is a substring of the expected error, empty when the file is
+		// valid.
+		err string
+	}{
+		{
+			name: "valid",
+			content: `suppressions:
+  - fingerprint: genuinetools/audit:deploy-key-write-access:MDk6UHVibGljS2V5MTIzNA==
+    reason: CI deploy key
+  - fingerprint: genuinetools/audit:hook-inactive:5678
+    expires: 2019-06-30
+`,
+		},
+		{"bad-yaml", "suppressions:\n  - fingerprint: [a\n", "parsing suppressions file"},
+		{"unknown-key", "suppressions:\n  - fingerprint: a:b:c\n    until: 2019-06-30\n", "field until not found"},
+		{"no-fingerprint", "suppressions:\n  - reason: accepted\n", "suppression 1 in suppressions file"},
+		{"duplicate", "suppressions:\n  - fingerprint: a:b:c\n  - fingerprint: a:b:c\n", "a:b:c is suppressed more than once"},
+		{"invalid-date", "suppressions:\n  - fingerprint: a:b:c\n    expires: 30/06/2019\n", `invalid expiry date "30/06/2019"`},
+	} {
+		s, err := loadSuppressions(writeSuppressions(t, dir, tc.name, tc.content))
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			} else if len(s.byFingerprint) != 2 {
+				t.Errorf("%s: %d suppressions, want 2", tc.name, len(s.byFingerprint))
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: error = %v, want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestSuppressionsFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit-suppressions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := loadSuppressions(writeSuppressions(t, dir, "suppressions", `suppressions:
+  - fingerprint: genuinetools/audit:deploy-key-write-access:MDk6UHVibGljS2V5MTIzNA==
+  - fingerprint: genuinetools/audit:hook-inactive:5678
+    expires: 2019-06-30
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := newFinding(ruleDeployKeyWriteAccess, "genuinetools/audit", "MDk6UHVibGljS2V5MTIzNA==", "deploy key has write access")
+	hook := newFinding(ruleHookInactive, "genuinetools/audit", "5678", "hook is inactive")
+	otherRepo := newFinding(ruleDeployKeyWriteAccess, "genuinetools/img", "MDk6UHVibGljS2V5MTIzNA==", "deploy key has write access")
+	otherKey := newFinding(ruleDeployKeyWriteAccess, "genuinetools/audit", "MDk6UHVibGljS2V5OTk5OQ==", "deploy key has write access")
+	findings := []finding{key, hook, otherRepo, otherKey}
+
+	for _, tc := range []struct {
+		name             string
+		now              time.Time
+		kept, suppressed []finding
+	}{
+		{
+			name:       "before expiry",
+			now:        time.Date(2019, 6, 29, 12, 0, 0, 0, time.UTC),
+			kept:       []finding{otherRepo, otherKey},
+			suppressed: []finding{key, hook},
+		},
+		{
+			name:       "last second before expiry",
+			now:        time.Date(2019, 6, 29, 23, 59, 59, 0, time.UTC),
+			kept:       []finding{otherRepo, otherKey},
+			suppressed: []finding{key, hook},
+		},
+		{
+			name:       "on the expiry date",
+			now:        time.Date(2019, 6, 30, 0, 0, 0, 0, time.UTC),
+			kept:       []finding{hook, otherRepo, otherKey},
+			suppressed: []finding{key},
+		},
+		{
+			name:       "after expiry",
+			now:        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			kept:       []finding{hook, otherRepo, otherKey},
+			suppressed: []finding{key},
+		},
+	} {
+		kept, suppressed := s.filter(findings, tc.now)
+		if !reflect.DeepEqual(kept, tc.kept) {
+			t.Errorf("%s: kept = %v, want %v", tc.name, kept, tc.kept)
+		}
+		if !reflect.DeepEqual(suppressed, tc.suppressed) {
+			t.Errorf("%s: suppressed = %v, want %v", tc.name, suppressed, tc.suppressed)
+		}
+	}
+}
+
+func TestSuppressionsApplyToWorkflowIssues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit-suppressions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	accepted := workflowIssue{Rule: ruleWorkflowWriteAll, Identifier: ".github/workflows/label.yml", Message: "GITHUB_TOKEN has write-all permissions"}
+	reported := workflowIssue{Rule: ruleWorkflowWriteAll, Identifier: ".github/workflows/build.yml", Message: "GITHUB_TOKEN has write-all permissions"}
+	s, err := loadSuppressions(writeSuppressions(t, dir, "suppressions", `suppressions:
+  - fingerprint: genuinetools/audit:`+accepted.Rule+`:`+accepted.Identifier+`
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &repoReport{
+		Repository: "genuinetools/audit",
+		Workflows: []workflowReport{
+			{Path: ".github/workflows/label.yml", Issues: []workflowIssue{accepted}},
+			{Path: ".github/workflows/build.yml", Issues: []workflowIssue{reported}},
+		},
+	}
+	r.Findings = workflowFindings(r)
+	s.apply(r, time.Now())
+
+	if len(r.Findings) != 1 || r.Findings[0].Identifier != reported.Identifier {
+		t.Errorf("findings = %v, want only the one of %s", r.Findings, reported.Identifier)
+	}
+	if len(r.Suppressed) != 1 || r.Suppressed[0].Identifier != accepted.Identifier {
+		t.Errorf("suppressed = %v, want only the one of %s", r.Suppressed, accepted.Identifier)
+	}
+	if len(r.Workflows[0].Issues) != 0 {
+		t.Errorf("suppressed workflow issue is still listed: %v", r.Workflows[0].Issues)
+	}
+	if !reflect.DeepEqual(r.Workflows[1].Issues, []workflowIssue{reported}) {
+		t.Errorf("workflow issues = %v, want %v", r.Workflows[1].Issues, []workflowIssue{reported})
+	}
+}
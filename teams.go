@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+// teamCache holds the members and child teams of every team seen during the
+// run so each team is only listed once, no matter how many repositories it
+// has access to. It is safe for concurrent use.
+type teamCache struct {
+	restClient *github.Client
+
+	mu       sync.Mutex
+	teams    map[int64]*teamMembers
+	children map[int64]*childTeams
+}
+
+// teamMembers holds the logins of the members of a team, lowercased.
+type teamMembers struct {
+	once   sync.Once
+	logins map[string]bool
+	err    error
+}
+
+// childTeams holds the child teams of a team.
+type childTeams struct {
+	once  sync.Once
+	teams []*github.Team
+	err   error
+}
+
+// newTeamCache returns an empty teamCache.
+func newTeamCache(restClient *github.Client) *teamCache {
+	return &teamCache{
+		restClient: restClient,
+		teams:      map[int64]*teamMembers{},
+		children:   map[int64]*childTeams{},
+	}
+}
+
+// isMember reports whether the user is a member of the team, including the
+// members of its child teams.
+func (c *teamCache) isMember(ctx context.Context, teamID int64, login string) (bool, error) {
+	logins, err := c.members(ctx, teamID)
+	if err != nil {
+		return false, err
+	}
+	return logins[strings.ToLower(login)], nil
+}
+
+// teamsBySlug indexes the teams by slug.
+func teamsBySlug(teams []*github.Team) map[string]*github.Team {
+	bySlug := map[string]*github.Team{}
+	for _, t := range teams {
+		bySlug[t.GetSlug()] = t
+	}
+	return bySlug
+}
+
+// inheritedVia returns, for every team the collaborator gets their access
+// from, keyed by slug, the name of the child team they are a member of when
+// they inherit the access through it. teams holds the teams with access to
+// the repository by slug. Teams whose members or children could not be listed
+// are returned as errors.
+func (c *teamCache) inheritedVia(ctx context.Context, teams map[string]*github.Team, edge collaboratorEdge) (map[string]string, []string) {
+	via := map[string]string{}
+	errs := []string{}
+	for _, s := range edge.PermissionSources {
+		t, ok := teams[s.Source.Slug]
+		if s.Source.Typename != "Team" || !ok {
+			continue
+		}
+		member, err := c.memberTeam(ctx, t, edge.Node.Login)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("finding the team of %s under team %s failed: %v", edge.Node.Login, t.GetName(), err))
+			continue
+		}
+		if member.GetID() != t.GetID() {
+			via[s.Source.Slug] = member.GetName()
+		}
+	}
+	return via, errs
+}
+
+// memberTeam returns the team the user is a member of out of the team and its
+// descendants, following the child teams the user is a member of down as far
+// as they go.
+func (c *teamCache) memberTeam(ctx context.Context, team *github.Team, login string) (*github.Team, error) {
+	children, err := c.childTeams(ctx, team.GetID())
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		// The members of a team include those of its child teams.
+		isMember, err := c.isMember(ctx, child.GetID(), login)
+		if err != nil {
+			return nil, err
+		}
+		if isMember {
+			return c.memberTeam(ctx, child, login)
+		}
+	}
+	return team, nil
+}
+
+// childTeams returns the child teams of the team, listing them the first time
+// the team is asked for.
+func (c *teamCache) childTeams(ctx context.Context, teamID int64) ([]*github.Team, error) {
+	c.mu.Lock()
+	t, ok := c.children[teamID]
+	if !ok {
+		t = &childTeams{}
+		c.children[teamID] = t
+	}
+	c.mu.Unlock()
+
+	t.once.Do(func() {
+		t.teams, t.err = c.listChildTeams(ctx, teamID)
+	})
+	return t.teams, t.err
+}
+
+// listChildTeams lists the child teams of the team.
+func (c *teamCache) listChildTeams(ctx context.Context, teamID int64) ([]*github.Team, error) {
+	logrus.Debugf("Executing REST query to list child teams of team %d", teamID)
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+
+	teams := []*github.Team{}
+	for {
+		var page []*github.Team
+		resp, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			page, resp, err = c.restClient.Teams.ListChildTeams(ctx, teamID, opt)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return teams, nil
+}
+
+// members returns the members of the team, listing them the first time the
+// team is asked for.
+func (c *teamCache) members(ctx context.Context, teamID int64) (map[string]bool, error) {
+	c.mu.Lock()
+	m, ok := c.teams[teamID]
+	if !ok {
+		m = &teamMembers{}
+		c.teams[teamID] = m
+	}
+	c.mu.Unlock()
+
+	m.once.Do(func() {
+		m.logins, m.err = c.listMembers(ctx, teamID)
+	})
+	return m.logins, m.err
+}
+
+// listMembers lists every member of the team.
+func (c *teamCache) listMembers(ctx context.Context, teamID int64) (map[string]bool, error) {
+	logrus.Debugf("Executing REST query to list members of team %d", teamID)
+	opt := &github.TeamListTeamMembersOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	logins := map[string]bool{}
+	for {
+		var users []*github.User
+		resp, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			users, resp, err = c.restClient.Teams.ListTeamMembers(ctx, teamID, opt)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			logins[strings.ToLower(u.GetLogin())] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return logins, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestInheritedVia(t *testing.T) {
+	// platform (1) has the child teams web (2) and sre (3), sre has the
+	// child team oncall (4). jess is in oncall, sam in platform itself.
+	responses := map[string]string{
+		"/teams/1/teams":   `[{"id": 2, "slug": "web", "name": "Web"}, {"id": 3, "slug": "sre", "name": "SRE"}]`,
+		"/teams/2/teams":   `[]`,
+		"/teams/3/teams":   `[{"id": 4, "slug": "oncall", "name": "On-call"}]`,
+		"/teams/4/teams":   `[]`,
+		"/teams/2/members": `[{"login": "kim"}]`,
+		"/teams/3/members": `[{"login": "jess"}]`,
+		"/teams/4/members": `[{"login": "Jess"}]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	restClient := github.NewClient(srv.Client())
+	restClient.BaseURL, _ = url.Parse(srv.URL + "/")
+	cache := newTeamCache(restClient)
+	teams := teamsBySlug([]*github.Team{
+		{ID: github.Int64(1), Slug: github.String("platform"), Name: github.String("Platform")},
+	})
+
+	for login, want := range map[string]string{"jess": "On-call", "sam": ""} {
+		edge := collaboratorEdge{PermissionSources: []collaboratorPermission{
+			{Permission: "WRITE", Source: permissionGranter{Typename: "Team", Slug: "platform"}},
+		}}
+		edge.Node.Login = login
+
+		via, errs := cache.inheritedVia(context.Background(), teams, edge)
+		if len(errs) > 0 {
+			t.Fatalf("%s: unexpected errors: %v", login, errs)
+		}
+		if via["platform"] != want {
+			t.Errorf("%s: via = %q, want %q", login, via["platform"], want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+const whoHelp = `Show every repository a user has access to, and why.`
+
+type whoCommand struct{}
+
+func (cmd *whoCommand) Name() string      { return "who" }
+func (cmd *whoCommand) Args() string      { return "[OPTIONS] LOGIN" }
+func (cmd *whoCommand) ShortHelp() string { return whoHelp }
+func (cmd *whoCommand) LongHelp() string {
+	return whoHelp + `
+
+Every audited repository the user is a collaborator on is listed with their
+permission and where it comes from: a direct grant, their teams or the
+organization base permission. Users who are not members of the organization
+owning a repository are flagged as outside collaborators. Use -format json to
+get the result as JSON.`
+}
+func (cmd *whoCommand) Hidden() bool { return false }
+
+func (cmd *whoCommand) Register(fs *flag.FlagSet) {}
+
+func (cmd *whoCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("who takes exactly one login")
+	}
+	login := args[0]
+
+	if err := checkAuditFlags(); err != nil {
+		return err
+	}
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("unknown who format %q (must be one of: %s, %s)", format, formatText, formatJSON)
+	}
+
+	ctx = handleSignals(ctx)
+
+	access := &accessReporter{w: os.Stdout, json: format == formatJSON, logins: []string{login}, access: map[string][]userAccess{}}
+	summary := &summaryReporter{reporter: access, w: os.Stderr}
+
+	a, username, err := newAuditor(ctx, summary)
+	if err != nil {
+		return err
+	}
+	a.handle = a.handleUsers([]string{login})
+
+	a.auditAll(ctx, username, summary)
+	if err := summary.Close(); err != nil {
+		return err
+	}
+
+	if code := summary.exitCode(""); code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
+// handleUsers returns a handler only auditing the access of the users to the
+// repository, skipping the repositories they have no access to.
+func (a *auditor) handleUsers(logins []string) func(ctx context.Context, repo ghrepo) (*repoReport, error) {
+	wanted := map[string]bool{}
+	for _, l := range logins {
+		wanted[strings.ToLower(l)] = true
+	}
+	orgs := newOrgMembership(a.restClient)
+
+	return func(ctx context.Context, repo ghrepo) (*repoReport, error) {
+		edges := []collaboratorEdge{}
+		for _, c := range repo.Collaborators.Edges {
+			if wanted[strings.ToLower(c.Node.Login)] {
+				edges = append(edges, c)
+			}
+		}
+		if len(edges) < 1 {
+			return nil, nil
+		}
+
+		report := newRepoReport(repo)
+
+		logrus.Debugf("Executing REST query to list teams for %s", repo.NameWithOwner)
+		var teams []*github.Team
+		_, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			teams, resp, err = a.restClient.Repositories.ListTeams(ctx, repo.Owner.Login, repo.Name, &github.ListOptions{PerPage: 100})
+			return resp, err
+		})
+		if err != nil {
+			if isRateLimit(err) {
+				return nil, err
+			}
+
+			report.Errors = append(report.Errors, fmt.Sprintf("listing teams failed: %v", err))
+		}
+		bySlug := teamsBySlug(teams)
+		for _, c := range edges {
+			via, errs := a.teams.inheritedVia(ctx, bySlug, c)
+			report.Errors = append(report.Errors, errs...)
+			collaborator := explainPermission(c, bySlug, via)
+			if repo.Owner.Typename == "Organization" {
+				member, err := orgs.isMember(ctx, repo.Owner.Login, c.Node.Login)
+				if err != nil {
+					if isRateLimit(err) {
+						return nil, err
+					}
+					report.Errors = append(report.Errors, fmt.Sprintf("checking membership of %s in %s failed: %v", c.Node.Login, repo.Owner.Login, err))
+				} else {
+					collaborator.OutsideCollaborator = !member
+				}
+			}
+			report.Collaborators = append(report.Collaborators, collaborator)
+		}
+
+		return report, nil
+	}
+}
+
+// orgMembership caches whether users are members of organizations. It is safe
+// for concurrent use.
+type orgMembership struct {
+	restClient *github.Client
+
+	mu      sync.Mutex
+	members map[string]*membership
+}
+
+// membership holds whether a user is a member of an organization.
+type membership struct {
+	once   sync.Once
+	member bool
+	err    error
+}
+
+// newOrgMembership returns an empty orgMembership.
+func newOrgMembership(restClient *github.Client) *orgMembership {
+	return &orgMembership{
+		restClient: restClient,
+		members:    map[string]*membership{},
+	}
+}
+
+// isMember reports whether the user is a member of the organization, checking
+// it the first time the user and organization are asked for.
+func (o *orgMembership) isMember(ctx context.Context, org, login string) (bool, error) {
+	key := strings.ToLower(org + "/" + login)
+
+	o.mu.Lock()
+	m, ok := o.members[key]
+	if !ok {
+		m = &membership{}
+		o.members[key] = m
+	}
+	o.mu.Unlock()
+
+	m.once.Do(func() {
+		logrus.Debugf("Executing REST query to check if %s is a member of %s", login, org)
+		_, m.err = waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			m.member, resp, err = o.restClient.Organizations.IsMember(ctx, org, login)
+			return resp, err
+		})
+	})
+	return m.member, m.err
+}
+
+// userAccess is the access of a user to a repository.
+type userAccess struct {
+	Repository string `json:"repository"`
+	URL        string `json:"url"`
+	Permission string `json:"permission"`
+	// Sources explains where the permission comes from.
+	Sources             []permissionSource `json:"sources"`
+	OutsideCollaborator bool               `json:"outsideCollaborator"`
+}
+
+// accessReporter is a reporter indexing the access of users across every
+// repository, written on Close.
+type accessReporter struct {
+	w    io.Writer
+	json bool
+
+	logins []string
+	// access holds the access of every user, keyed by lowercased login.
+	access map[string][]userAccess
+}
+
+func (u *accessReporter) Report(r *repoReport) error {
+	for _, c := range r.Collaborators {
+		login := strings.ToLower(c.Login)
+		u.access[login] = append(u.access[login], userAccess{
+			Repository:          r.Repository,
+			URL:                 r.URL,
+			Permission:          c.Permission,
+			Sources:             c.Sources,
+			OutsideCollaborator: c.OutsideCollaborator,
+		})
+	}
+	return nil
+}
+
+// userRepositories holds the repositories a user has access to.
+type userRepositories struct {
+	Login        string       `json:"login"`
+	Repositories []userAccess `json:"repositories"`
+}
+
+// users returns the access of every user, in the order they were asked for.
+func (u *accessReporter) users() []userRepositories {
+	users := []userRepositories{}
+	for _, l := range u.logins {
+		access := u.access[strings.ToLower(l)]
+		if access == nil {
+			access = []userAccess{}
+		}
+		users = append(users, userRepositories{Login: l, Repositories: access})
+	}
+	return users
+}
+
+func (u *accessReporter) Close() error {
+	users := u.users()
+
+	if u.json {
+		enc := json.NewEncoder(u.w)
+		enc.SetIndent("", "  ")
+		if len(users) == 1 {
+			return enc.Encode(users[0])
+		}
+		return enc.Encode(users)
+	}
+
+	for _, user := range users {
+		if _, err := io.WriteString(u.w, user.text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// text returns the repositories of the user grouped by permission, in the
+// same layout as the text report.
+func (user userRepositories) text() string {
+	output := fmt.Sprintf("%s -> \n", user.Login)
+	if len(user.Repositories) < 1 {
+		return output + "\tNo access to any audited repository\n--\n\n"
+	}
+
+	perms := map[string][]string{}
+	for _, r := range user.Repositories {
+		sources := []string{}
+		for _, s := range r.Sources {
+			sources = append(sources, s.String())
+		}
+		line := fmt.Sprintf("\t\t\t%s (%s)", r.Repository, strings.Join(sources, ", "))
+		if r.OutsideCollaborator {
+			line += " [outside collaborator]"
+		}
+		perms[r.Permission] = append(perms[r.Permission], line)
+	}
+	output += fmt.Sprintf("\tRepositories (%d):\n", len(user.Repositories))
+	output += fmt.Sprintf("\t\tAdmin (%d):\n%s\n", len(perms["ADMIN"]), strings.Join(perms["ADMIN"], "\n"))
+	output += fmt.Sprintf("\t\tMaintain (%d):\n%s\n", len(perms["MAINTAIN"]), strings.Join(perms["MAINTAIN"], "\n"))
+	output += fmt.Sprintf("\t\tTriage (%d):\n%s\n", len(perms["TRIAGE"]), strings.Join(perms["TRIAGE"], "\n"))
+	output += fmt.Sprintf("\t\tWrite (%d):\n%s\n", len(perms["WRITE"]), strings.Join(perms["WRITE"], "\n"))
+	output += fmt.Sprintf("\t\tRead (%d):\n%s\n", len(perms["READ"]), strings.Join(perms["READ"], "\n"))
+	return output + "--\n\n"
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// workflowsDir is where GitHub Actions workflows live in a repository.
+const workflowsDir = ".github/workflows"
+
+var (
+	// expressionRe matches the ${{ }} expressions of a workflow.
+	expressionRe = regexp.MustCompile(`\$\{\{(.*?)\}\}`)
+	// shaRe matches a full commit SHA.
+	shaRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+	// untrustedInputs match the contexts an outside contributor controls, so
+	// interpolating them into a script lets them inject commands.
+	// See https://securitylab.github.com/research/github-actions-untrusted-input/
+	untrustedInputs = []*regexp.Regexp{
+		regexp.MustCompile(`github\.event\.(issue|pull_request|discussion)\.(title|body)`),
+		regexp.MustCompile(`github\.event\.(comment|review|review_comment)\.body`),
+		regexp.MustCompile(`github\.event\.pages\.[^.]+\.page_name`),
+		regexp.MustCompile(`github\.event\.(commits\.[^.]+|head_commit)\.(message|author\.(email|name))`),
+		regexp.MustCompile(`github\.event\.pull_request\.head\.(ref|label|repo\.default_branch)`),
+		regexp.MustCompile(`github\.event\.workflow_run\.(head_branch|head_commit\.(message|author\.(email|name)))`),
+		regexp.MustCompile(`github\.head_ref`),
+	}
+
+	// untrustedRefs match the refs of the code of a pull request, or of the
+	// run that triggered a workflow_run.
+	untrustedRefs = []*regexp.Regexp{
+		regexp.MustCompile(`github\.event\.pull_request\.head\.(sha|ref)`),
+		regexp.MustCompile(`github\.event\.workflow_run\.head_(sha|branch)`),
+		regexp.MustCompile(`github\.head_ref`),
+		regexp.MustCompile(`refs/pull/`),
+	}
+)
+
+// workflow is the part of a workflow file the audit looks at. The triggers
+// are read separately, as YAML 1.1 parses the "on" key as a boolean.
+type workflow struct {
+	Permissions interface{}            `yaml:"permissions"`
+	Jobs        map[string]workflowJob `yaml:"jobs"`
+}
+
+type workflowJob struct {
+	Permissions interface{} `yaml:"permissions"`
+	// Uses is set for jobs calling a reusable workflow.
+	Uses  string         `yaml:"uses"`
+	Steps []workflowStep `yaml:"steps"`
+}
+
+type workflowStep struct {
+	Uses string                 `yaml:"uses"`
+	Run  string                 `yaml:"run"`
+	With map[string]interface{} `yaml:"with"`
+}
+
+// auditWorkflows fetches the workflows on the default branch of the
+// repository and looks for dangerous patterns in them, skipping the ones that
+// could not be fetched or parsed with an error.
+func (a *auditor) auditWorkflows(ctx context.Context, repo ghrepo) ([]workflowReport, []string, error) {
+	reports := []workflowReport{}
+	errs := []string{}
+	if repo.DefaultBranchRef.Name == "" {
+		return reports, errs, nil
+	}
+	opt := &github.RepositoryContentGetOptions{Ref: repo.DefaultBranchRef.Name}
+
+	logrus.Debugf("Executing REST query to list workflows for %s", repo.NameWithOwner)
+	var files []*github.RepositoryContent
+	resp, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+		_, files, resp, err = a.restClient.Repositories.GetContents(ctx, repo.Owner.Login, repo.Name, workflowsDir, opt)
+		return resp, err
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return reports, errs, nil
+		}
+		if isRateLimit(err) {
+			return nil, nil, err
+		}
+		return reports, append(errs, fmt.Sprintf("listing workflows failed: %v", err)), nil
+	}
+
+	for _, f := range files {
+		if f.GetType() != "file" || (path.Ext(f.GetName()) != ".yml" && path.Ext(f.GetName()) != ".yaml") {
+			continue
+		}
+
+		logrus.Debugf("Executing REST query to get workflow %s for %s", f.GetPath(), repo.NameWithOwner)
+		var file *github.RepositoryContent
+		_, err := waitRateLimit(ctx, func() (resp *github.Response, err error) {
+			file, _, resp, err = a.restClient.Repositories.GetContents(ctx, repo.Owner.Login, repo.Name, f.GetPath(), opt)
+			return resp, err
+		})
+		if err != nil {
+			if isRateLimit(err) {
+				return nil, nil, err
+			}
+			errs = append(errs, fmt.Sprintf("getting workflow %s failed: %v", f.GetPath(), err))
+			continue
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("decoding workflow %s failed: %v", f.GetPath(), err))
+			continue
+		}
+
+		report, err := analyzeWorkflow(f.GetPath(), []byte(content))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, errs, nil
+}
+
+// analyzeWorkflow parses the workflow file at path and reports the dangerous
+// patterns in it.
+func analyzeWorkflow(path string, b []byte) (workflowReport, error) {
+	report := workflowReport{
+		Path:     path,
+		Triggers: []string{},
+		Issues:   []workflowIssue{},
+	}
+
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return report, fmt.Errorf("parsing workflow %s failed: %v", path, err)
+	}
+	var w workflow
+	if err := yaml.Unmarshal(b, &w); err != nil {
+		return report, fmt.Errorf("parsing workflow %s failed: %v", path, err)
+	}
+
+	on, ok := raw["on"]
+	if !ok {
+		on = raw[true]
+	}
+	report.Triggers = workflowTriggers(on)
+	// Workflows triggered by these events run with the secrets and a
+	// writable token of the base repository.
+	privileged := []string{}
+	for _, t := range []string{"pull_request_target", "workflow_run"} {
+		if contains(report.Triggers, t) {
+			privileged = append(privileged, t)
+		}
+	}
+
+	if w.Permissions == "write-all" {
+		report.Issues = append(report.Issues, workflowIssue{
+			Rule:       ruleWorkflowWriteAll,
+			Identifier: path,
+			Message:    fmt.Sprintf("%s grants every job write access to everything with permissions: write-all", path),
+		})
+	}
+
+	jobs := []string{}
+	for name := range w.Jobs {
+		jobs = append(jobs, name)
+	}
+	sort.Strings(jobs)
+
+	for _, name := range jobs {
+		job := w.Jobs[name]
+		id := path + ":" + name
+
+		if job.Permissions == "write-all" {
+			report.Issues = append(report.Issues, workflowIssue{
+				Rule:       ruleWorkflowWriteAll,
+				Identifier: id,
+				Message:    fmt.Sprintf("job %s of %s has permissions: write-all", name, path),
+			})
+		}
+		if job.Uses != "" && !pinned(job.Uses) {
+			report.Issues = append(report.Issues, workflowIssue{
+				Rule:       ruleWorkflowUnpinnedAction,
+				Identifier: id + ":" + job.Uses,
+				Message:    fmt.Sprintf("job %s of %s calls %s, which is not pinned to a full commit SHA", name, path, job.Uses),
+			})
+		}
+
+		checkedOut := false
+		for _, step := range job.Steps {
+			if step.Uses != "" && !pinned(step.Uses) {
+				report.Issues = append(report.Issues, workflowIssue{
+					Rule:       ruleWorkflowUnpinnedAction,
+					Identifier: id + ":" + step.Uses,
+					Message:    fmt.Sprintf("job %s of %s uses %s, which is not pinned to a full commit SHA", name, path, step.Uses),
+				})
+			}
+
+			if len(privileged) > 0 && !checkedOut && checksOutUntrusted(step) {
+				checkedOut = true
+				report.Issues = append(report.Issues, workflowIssue{
+					Rule:       ruleWorkflowUntrustedCheckout,
+					Identifier: id,
+					Message:    fmt.Sprintf("job %s of %s checks out untrusted code on %s, which runs with secrets and a writable token", name, path, strings.Join(privileged, " and ")),
+				})
+			}
+
+			// actions/github-script runs its script input the same way a
+			// shell runs a run script.
+			script := step.Run
+			if strings.HasPrefix(step.Uses, "actions/github-script@") {
+				script, _ = step.With["script"].(string)
+			}
+			for _, expr := range untrustedExpressions(script) {
+				report.Issues = append(report.Issues, workflowIssue{
+					Rule:       ruleWorkflowScriptInjection,
+					Identifier: id + ":" + expr,
+					Message:    fmt.Sprintf("job %s of %s interpolates %s into a script, so its content can inject commands", name, path, expr),
+				})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// workflowTriggers returns the events the "on" value of a workflow triggers
+// on, it is either an event, a list of events or a map keyed by event.
+func workflowTriggers(on interface{}) []string {
+	triggers := []string{}
+	switch on := on.(type) {
+	case string:
+		triggers = append(triggers, on)
+	case []interface{}:
+		for _, t := range on {
+			if s, ok := t.(string); ok {
+				triggers = append(triggers, s)
+			}
+		}
+	case map[interface{}]interface{}:
+		for t := range on {
+			if s, ok := t.(string); ok {
+				triggers = append(triggers, s)
+			}
+		}
+	}
+	sort.Strings(triggers)
+	return triggers
+}
+
+// pinned reports whether the action or reusable workflow is pinned to a full
+// commit SHA. Local actions, Docker images and the actions published by
+// GitHub itself are trusted.
+func pinned(uses string) bool {
+	if strings.HasPrefix(uses, "./") || strings.HasPrefix(uses, "docker://") {
+		return true
+	}
+	if strings.HasPrefix(uses, "actions/") || strings.HasPrefix(uses, "github/") {
+		return true
+	}
+	i := strings.LastIndex(uses, "@")
+	if i < 0 {
+		return false
+	}
+	return shaRe.MatchString(uses[i+1:])
+}
+
+// checksOutUntrusted reports whether the step checks out the code of a pull
+// request or of the run that triggered the workflow.
+func checksOutUntrusted(step workflowStep) bool {
+	s := step.Run
+	switch {
+	case strings.HasPrefix(step.Uses, "actions/checkout@"):
+		s, _ = step.With["ref"].(string)
+	case strings.Contains(s, "gh pr checkout"):
+		return true
+	case !strings.Contains(s, "git fetch") && !strings.Contains(s, "git checkout"):
+		return false
+	}
+	for _, re := range untrustedRefs {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// untrustedExpressions returns the untrusted inputs interpolated into the
+// script, once each.
+func untrustedExpressions(script string) []string {
+	exprs := []string{}
+	for _, m := range expressionRe.FindAllStringSubmatch(script, -1) {
+		expr := strings.TrimSpace(m[1])
+		for _, re := range untrustedInputs {
+			if re.MatchString(expr) && !contains(exprs, expr) {
+				exprs = append(exprs, expr)
+				break
+			}
+		}
+	}
+	return exprs
+}
+
+// workflowFindings turns the issues found in the workflows of a repository
+// into findings.
+func workflowFindings(r *repoReport) []finding {
+	findings := []finding{}
+	for _, w := range r.Workflows {
+		for _, i := range w.Issues {
+			findings = append(findings, newFinding(i.Rule, r.Repository, i.Identifier, i.Message))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeWorkflow(t *testing.T) {
+	tests := []struct {
+		file     string
+		triggers []string
+		// issues holds the rule and identifier of every issue, in order.
+		issues [][2]string
+	}{
+		{
+			file:     "on-true.yml",
+			triggers: []string{"pull_request", "push"},
+		},
+		{
+			file:     "untrusted-checkout.yml",
+			triggers: []string{"pull_request_target"},
+			issues: [][2]string{
+				{ruleWorkflowUntrustedCheckout, "untrusted-checkout.yml:build"},
+				{ruleWorkflowUntrustedCheckout, "untrusted-checkout.yml:label"},
+			},
+		},
+		{
+			file:     "pull-request-checkout.yml",
+			triggers: []string{"pull_request"},
+		},
+		{
+			file:     "pins.yml",
+			triggers: []string{"push"},
+			issues: [][2]string{
+				{ruleWorkflowUnpinnedAction, "pins.yml:build:docker/login-action@v3"},
+				{ruleWorkflowUnpinnedAction, "pins.yml:build:docker/setup-buildx-action@master"},
+				{ruleWorkflowUnpinnedAction, "pins.yml:release:octo-org/workflows/.github/workflows/release.yml@main"},
+			},
+		},
+		{
+			file:     "script-injection.yml",
+			triggers: []string{"issue_comment", "issues"},
+			issues: [][2]string{
+				{ruleWorkflowScriptInjection, "script-injection.yml:triage:github.event.issue.title"},
+				{ruleWorkflowScriptInjection, "script-injection.yml:triage:github.event.comment.body"},
+				{ruleWorkflowScriptInjection, "script-injection.yml:triage:github.event.issue.body"},
+			},
+		},
+		{
+			file:     "write-all.yml",
+			triggers: []string{"workflow_dispatch"},
+			issues: [][2]string{
+				{ruleWorkflowWriteAll, "write-all.yml"},
+				{ruleWorkflowWriteAll, "write-all.yml:deploy"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", "workflows", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := analyzeWorkflow(tt.file, b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(report.Triggers, tt.triggers) {
+			t.Errorf("%s: triggers = %v, want %v", tt.file, report.Triggers, tt.triggers)
+		}
+		issues := [][2]string{}
+		for _, i := range report.Issues {
+			issues = append(issues, [2]string{i.Rule, i.Identifier})
+		}
+		if tt.issues == nil {
+			tt.issues = [][2]string{}
+		}
+		if !reflect.DeepEqual(issues, tt.issues) {
+			t.Errorf("%s: issues = %v, want %v", tt.file, issues, tt.issues)
+		}
+	}
+}
+
+func TestAnalyzeWorkflowInvalid(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "workflows", "invalid.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := analyzeWorkflow("invalid.yml", b); err == nil {
+		t.Error("expected an error for an invalid workflow")
+	}
+}
+
+func TestPinned(t *testing.T) {
+	tests := map[string]bool{
+		"actions/checkout@v4":                                          true,
+		"github/codeql-action/init@v3":                                 true,
+		"./.github/actions/setup":                                      true,
+		"docker://alpine:3.19":                                         true,
+		"docker/login-action@v3":                                       false,
+		"docker/login-action@main":                                     false,
+		"docker/login-action":                                          false,
+		"docker/login-action@4a13e500e55cf31b7a5d59a38ab2040a":         false,
+		"docker/login-action@4a13e500e55cf31b7a5d59a38ab2040ab0f42f56": true,
+		"octo-org/workflows/.github/workflows/release.yml@4a13e500e55cf31b7a5d59a38ab2040ab0f42f56": true,
+	}
+	for uses, want := range tests {
+		if got := pinned(uses); got != want {
+			t.Errorf("pinned(%q) = %t, want %t", uses, got, want)
+		}
+	}
+}
+
+func TestUntrustedExpressions(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{`echo "${{ github.event.pull_request.title }}"`, []string{"github.event.pull_request.title"}},
+		{`echo "${{github.event.discussion.body}}"`, []string{"github.event.discussion.body"}},
+		{`git push origin ${{ github.head_ref }}`, []string{"github.head_ref"}},
+		{`echo "${{ github.event.head_commit.message }}"`, []string{"github.event.head_commit.message"}},
+		{`echo "${{ github.event.pull_request.head.ref }}"`, []string{"github.event.pull_request.head.ref"}},
+		{`echo "${{ github.event.pull_request.number }} ${{ github.sha }}"`, []string{}},
+		{`echo "$TITLE"`, []string{}},
+	}
+	for _, tt := range tests {
+		if got := untrustedExpressions(tt.script); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("untrustedExpressions(%q) = %v, want %v", tt.script, got, tt.want)
+		}
+	}
+}